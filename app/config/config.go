@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/curry-mz/sagittarius-golang/nacos"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 /////////////////////////////////////////////////
@@ -139,8 +141,108 @@ type ClientConfig struct {
 	UnUseDiscovery bool `yaml:"unUseDiscovery" json:"unUseDiscovery" xml:"unUseDiscovery"`
 	// 重试次数
 	Retry int `yaml:"retry" json:"retry" xml:"retry"`
-	// 超时时间
+	// 超时时间 包含重试在内的整体超时 单次请求超时使用RetryPolicy.PerTryTimeout
+	// http的Do/Download只限制到收到响应header
 	Timeout string `yaml:"timeout" json:"timeout" xml:"timeout"`
+	// 重试策略 为空时使用默认策略
+	RetryPolicy *RetryConfig `yaml:"retryPolicy" json:"retryPolicy" xml:"retryPolicy"`
+	// 负载均衡策略 random/round_robin/weighted_round_robin/least_request/p2c/consistent_hash
+	// 为空时http使用random rpc使用round_robin 权重来自服务发现元数据weight
+	Balancer string `yaml:"balancer" json:"balancer" xml:"balancer"`
+	// 熔断配置 为空时不熔断 配置变更后立即生效
+	Breaker *BreakerConfig `yaml:"breaker" json:"breaker" xml:"breaker"`
+	// 异常节点摘除配置 为空时不摘除 配置变更后立即生效
+	Outlier *OutlierConfig `yaml:"outlier" json:"outlier" xml:"outlier"`
+	// 对冲请求配置 为空时不对冲
+	Hedge *HedgeConfig `yaml:"hedge" json:"hedge" xml:"hedge"`
+}
+
+// RetryConfig 客户端重试策略
+type RetryConfig struct {
+	// 首次重试等待时间 默认50ms
+	InitialBackoff string `yaml:"initialBackoff" json:"initialBackoff" xml:"initialBackoff"`
+	// 最大等待时间 默认1s
+	MaxBackoff string `yaml:"maxBackoff" json:"maxBackoff" xml:"maxBackoff"`
+	// 退避倍数 默认2
+	Multiplier float64 `yaml:"multiplier" json:"multiplier" xml:"multiplier"`
+	// 抖动比例 0-1 默认0.2
+	Jitter float64 `yaml:"jitter" json:"jitter" xml:"jitter"`
+	// 单次请求超时
+	PerTryTimeout string `yaml:"perTryTimeout" json:"perTryTimeout" xml:"perTryTimeout"`
+	// http可重试状态码 默认502/503/504
+	RetryableStatus []int `yaml:"retryableStatus" json:"retryableStatus" xml:"retryableStatus"`
+	// grpc可重试状态码 例如UNAVAILABLE 默认UNAVAILABLE/DEADLINE_EXCEEDED
+	RetryableCodes []string `yaml:"retryableCodes" json:"retryableCodes" xml:"retryableCodes"`
+	// 允许非幂等请求重试 默认false
+	NonIdempotent bool `yaml:"nonIdempotent" json:"nonIdempotent" xml:"nonIdempotent"`
+	// 幂等的grpc方法 例如/pkg.Service/Get
+	IdempotentMethods []string `yaml:"idempotentMethods" json:"idempotentMethods" xml:"idempotentMethods"`
+	// 重试预算 重试占调用量的比例 默认0.1 小于0时不限制
+	BudgetRatio float64 `yaml:"budgetRatio" json:"budgetRatio" xml:"budgetRatio"`
+	// 重试预算 每秒保底重试次数 默认10
+	BudgetMinPerSec int `yaml:"budgetMinPerSec" json:"budgetMinPerSec" xml:"budgetMinPerSec"`
+}
+
+// BreakerConfig 客户端熔断 下游整体和单个节点分别统计
+type BreakerConfig struct {
+	// 关闭熔断
+	Disable bool `yaml:"disable" json:"disable" xml:"disable"`
+	// 统计窗口 默认10s
+	Window string `yaml:"window" json:"window" xml:"window"`
+	// 窗口分桶数 默认10
+	Buckets int `yaml:"buckets" json:"buckets" xml:"buckets"`
+	// 窗口内最小请求数 达到后才计算比例 默认20
+	MinRequests int `yaml:"minRequests" json:"minRequests" xml:"minRequests"`
+	// 错误率阈值 0-1 默认0.5
+	ErrorRate float64 `yaml:"errorRate" json:"errorRate" xml:"errorRate"`
+	// 慢调用耗时 为空时不统计慢调用
+	SlowCall string `yaml:"slowCall" json:"slowCall" xml:"slowCall"`
+	// 慢调用率阈值 0-1 默认1
+	SlowCallRate float64 `yaml:"slowCallRate" json:"slowCallRate" xml:"slowCallRate"`
+	// 打开后进入半开的等待时间 默认5s
+	OpenTimeout string `yaml:"openTimeout" json:"openTimeout" xml:"openTimeout"`
+	// 半开状态探测请求数 默认5
+	HalfOpenRequests int `yaml:"halfOpenRequests" json:"halfOpenRequests" xml:"halfOpenRequests"`
+}
+
+// OutlierConfig 异常节点摘除 连续失败或错误率异常的节点一段时间内不参与负载均衡
+type OutlierConfig struct {
+	// 关闭摘除
+	Disable bool `yaml:"disable" json:"disable" xml:"disable"`
+	// 连续失败次数 默认5
+	ConsecutiveFailures int `yaml:"consecutiveFailures" json:"consecutiveFailures" xml:"consecutiveFailures"`
+	// 错误率统计周期 默认10s
+	Interval string `yaml:"interval" json:"interval" xml:"interval"`
+	// 基础摘除时间 第n次摘除为n倍 默认30s
+	BaseEjectionTime string `yaml:"baseEjectionTime" json:"baseEjectionTime" xml:"baseEjectionTime"`
+	// 最长摘除时间 默认300s
+	MaxEjectionTime string `yaml:"maxEjectionTime" json:"maxEjectionTime" xml:"maxEjectionTime"`
+	// 最多摘除的节点比例 0-100 默认10
+	MaxEjectionPercent int `yaml:"maxEjectionPercent" json:"maxEjectionPercent" xml:"maxEjectionPercent"`
+	// 成功率低于平均值减去该倍数标准差时摘除 默认1.9
+	ErrorRateStdev float64 `yaml:"errorRateStdev" json:"errorRateStdev" xml:"errorRateStdev"`
+	// 周期内请求数达到后才参与错误率计算 默认50
+	MinRequests int `yaml:"minRequests" json:"minRequests" xml:"minRequests"`
+	// 参与错误率计算的节点数下限 默认3
+	MinHosts int `yaml:"minHosts" json:"minHosts" xml:"minHosts"`
+}
+
+// HedgeConfig 客户端对冲请求 http只对冲幂等方法 grpc只对冲声明的方法
+type HedgeConfig struct {
+	// 包含首次请求在内的最多请求数 默认2
+	MaxAttempts int `yaml:"maxAttempts" json:"maxAttempts" xml:"maxAttempts"`
+	// 发送对冲请求前的等待时间 使用分位数时为样本不足时的等待时间 默认100ms
+	Delay string `yaml:"delay" json:"delay" xml:"delay"`
+	// 使用下游近期耗时的分位数作为等待时间 例如0.95 为0时使用固定等待时间
+	Percentile float64 `yaml:"percentile" json:"percentile" xml:"percentile"`
+	// 使用分位数时的最少样本数 默认100
+	MinSamples int `yaml:"minSamples" json:"minSamples" xml:"minSamples"`
+	// 幂等的grpc方法 例如/pkg.Service/Get
+	IdempotentMethods []string `yaml:"idempotentMethods" json:"idempotentMethods" xml:"idempotentMethods"`
+	// 对冲预算 对冲占调用量的比例 默认0.05 小于0时不限制
+	BudgetRatio float64 `yaml:"budgetRatio" json:"budgetRatio" xml:"budgetRatio"`
+	// 对冲预算 每秒保底对冲次数 默认5
+	BudgetMinPerSec int `yaml:"budgetMinPerSec" json:"budgetMinPerSec" xml:"budgetMinPerSec"`
 }
 
 // RocketProducerConfig rocket producer配置
@@ -434,3 +536,16 @@ func Initialize(sd *ServiceDefine, v interface{}, opts ...Option) (*nacos.Client
 	}
 	return cli, cfgStr, nil
 }
+
+// Parse 按nacos配置格式解析 用于配置变更
+func Parse(cfgStr string, v interface{}) error {
+	_, _, _, cfgFormat, _, _ := env.GetNacos()
+	switch strings.ToLower(cfgFormat) {
+	case "yaml":
+		return yaml.Unmarshal([]byte(cfgStr), v)
+	case "xml":
+		return xml.Unmarshal([]byte(cfgStr), v)
+	default:
+		return json.Unmarshal([]byte(cfgStr), v)
+	}
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/curry-mz/sagittarius-golang/app"
 	"github.com/curry-mz/sagittarius-golang/app/config"
+	"github.com/curry-mz/sagittarius-golang/cores/client/breaker"
+	"github.com/curry-mz/sagittarius-golang/cores/client/hedge"
 	"github.com/curry-mz/sagittarius-golang/cores/client/http"
+	"github.com/curry-mz/sagittarius-golang/cores/client/outlier"
+	"github.com/curry-mz/sagittarius-golang/cores/client/retry"
 	"github.com/curry-mz/sagittarius-golang/cores/client/rpc"
 	"github.com/curry-mz/sagittarius-golang/env"
 	"github.com/curry-mz/sagittarius-golang/logger"
@@ -25,6 +30,7 @@ import (
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -43,8 +49,38 @@ var (
 	_rocketConsumerMutex = sync.Mutex{}
 	_kafkaProducerMutex  = sync.Mutex{}
 	_kafkaConsumerMutex  = sync.Mutex{}
+
+	_closeOnce = sync.Once{}
 )
 
+// storeClient 缓存客户端 首次缓存时注册退出时关闭
+func storeClient(fullKey string, c interface{}) {
+	_closeOnce.Do(func() {
+		app.Router().OnShutdown(closeClients)
+	})
+	_client.Store(fullKey, c)
+}
+
+// closeClients 关闭缓存的http/rpc客户端 停止服务发现监听和连接
+func closeClients() {
+	_clientMutex.Lock()
+	defer _clientMutex.Unlock()
+	_client.Range(func(key, value interface{}) bool {
+		var err error
+		switch c := value.(type) {
+		case *grpc.ClientConn:
+			err = c.Close()
+		case *http.Client:
+			err = c.Close()
+		}
+		if err != nil {
+			logger.Error(app.Router().Ctx(), "client %v close, error:%v", key, err)
+		}
+		_client.Delete(key)
+		return true
+	})
+}
+
 // InitSqlClient 初始化mysql客户端
 func InitSqlClient(name string, opts ...mysql.Option) (*mysql.Client, error) {
 	_sqlMutex.Lock()
@@ -168,6 +204,9 @@ func InitRPCClient(ctx context.Context, name string, opts ...rpc.ClientOption) (
 		}
 		opts = append(opts, rpc.WithWatcher(watcher))
 	}
+	if config.Balancer != "" {
+		opts = append(opts, rpc.WithBalancerName(config.Balancer))
+	}
 	var timeout time.Duration
 	var err error
 	if config.Timeout != "" {
@@ -176,17 +215,54 @@ func InitRPCClient(ctx context.Context, name string, opts ...rpc.ClientOption) (
 			return nil, err
 		}
 	}
-	opts = append(opts, rpc.WithUnaryInterceptor(
-		rpc.RetryClientUnaryInterceptor(config.Retry),
+	policy, err := newRetryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	breakers, err := newBreakerGroup(fullKey, name, env.ProtoRPC, config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, rpc.WithBreaker(breakers))
+	detector, err := newOutlierDetector(fullKey, name, env.ProtoRPC, config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, rpc.WithOutlierDetector(detector))
+	hp, err := newHedgePolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	// 超时在重试之外 为包含重试在内的整体超时 对冲在重试之内
+	// 指标/access日志/熔断在对冲之内 每次请求都计入统计
+	ints := []grpc.UnaryClientInterceptor{
 		rpc.TimeoutClientUnaryInterceptor(timeout),
+		rpc.RetryPolicyClientUnaryInterceptor(policy),
+	}
+	if hp != nil {
+		ints = append(ints, rpc.HedgeClientUnaryInterceptor(hp))
+	}
+	ints = append(ints,
+		rpc.MetricsClientUnaryInterceptor(name),
+		rpc.AccessClientUnaryInterceptor(logger.GetAccess(), name),
+		rpc.BreakerClientUnaryInterceptor(breakers),
 		rpc.TracingClientUnaryInterceptor(app.Router().Ctx(), app.Router().Tracer()),
-		grpc_prometheus.UnaryClientInterceptor),
+		rpc.RequestIDClientUnaryInterceptor(),
+		grpc_prometheus.UnaryClientInterceptor,
 	)
+	opts = append(opts, rpc.WithUnaryInterceptor(ints...))
+	// 流不重试/对冲 超时为整个流的超时
+	opts = append(opts, rpc.WithStreamInterceptor(
+		rpc.TimeoutClientStreamInterceptor(timeout),
+		rpc.TracingClientStreamInterceptor(app.Router().Ctx(), app.Router().Tracer()),
+		rpc.RequestIDClientStreamInterceptor(),
+		grpc_prometheus.StreamClientInterceptor,
+	))
 	c, err := rpc.DialContext(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
-	_client.Store(fullKey, c)
+	storeClient(fullKey, c)
 	return c, nil
 }
 
@@ -220,6 +296,7 @@ func InitHttpClientUseConfig(ctx context.Context, config *config.ClientConfig) (
 	if err != nil {
 		return nil, err
 	}
+	// 与rpc相同 为包含重试在内的整体超时
 	opts = append(opts, http.WithTimeout(td))
 	if !config.UnUseDiscovery && app.Router().Discovery() != nil {
 		// 开始服务发现
@@ -229,14 +306,263 @@ func InitHttpClientUseConfig(ctx context.Context, config *config.ClientConfig) (
 		}
 		opts = append(opts, http.WithWatcher(watcher))
 	}
-	if config.Retry > 0 {
-		opts = append(opts, http.WithRetry(config.Retry))
+	if config.Balancer != "" {
+		opts = append(opts, http.WithBalancerName(config.Balancer))
 	}
+	policy, err := newRetryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, http.WithRetryPolicy(policy))
+	breakers, err := newBreakerGroup(fullKey, fullName, env.ProtoHttp, config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, http.WithBreaker(breakers))
+	detector, err := newOutlierDetector(fullKey, fullName, env.ProtoHttp, config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, http.WithOutlierDetector(detector))
+	hp, err := newHedgePolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	if hp != nil {
+		opts = append(opts, http.WithHedgePolicy(hp))
+	}
+	// 拦截器在重试和对冲之内 每次请求单独记录
+	// 下游为框架服务 解析envelope格式的业务错误
+	opts = append(opts, http.WithErrorDecoders(http.FrameworkErrorDecoder()))
 	opts = append(opts, http.WithInterceptors(
+		http.MetricsInterceptor(fullName),
+		http.AccessInterceptor(logger.GetAccess(), fullName),
+		http.BreakerInterceptor(breakers),
 		http.TracingInterceptor(app.Router().Ctx(), app.Router().Tracer()),
+		http.RequestIDInterceptor(),
 	))
 	c := http.NewClient(ctx, opts...)
-	_client.Store(fullKey, c)
+	storeClient(fullKey, c)
+	return c, nil
+}
+
+// newRetryPolicy 按配置生成重试策略 每个下游独立的重试预算
+func newRetryPolicy(cfg *config.ClientConfig) (*retry.Policy, error) {
+	opts := []retry.Option{retry.Attempts(cfg.Retry)}
+	rc := cfg.RetryPolicy
+	if rc == nil {
+		rc = &config.RetryConfig{}
+	}
+	initial, max := 50*time.Millisecond, time.Second
+	var err error
+	if rc.InitialBackoff != "" {
+		if initial, err = time.ParseDuration(rc.InitialBackoff); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("client retry policy, initialBackoff:%s", rc.InitialBackoff))
+		}
+	}
+	if rc.MaxBackoff != "" {
+		if max, err = time.ParseDuration(rc.MaxBackoff); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("client retry policy, maxBackoff:%s", rc.MaxBackoff))
+		}
+	}
+	opts = append(opts, retry.Backoff(initial, max))
+	if rc.Multiplier > 0 {
+		opts = append(opts, retry.Multiplier(rc.Multiplier))
+	}
+	if rc.Jitter > 0 {
+		opts = append(opts, retry.Jitter(rc.Jitter))
+	}
+	if rc.PerTryTimeout != "" {
+		td, err := time.ParseDuration(rc.PerTryTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("client retry policy, perTryTimeout:%s", rc.PerTryTimeout))
+		}
+		opts = append(opts, retry.PerAttemptTimeout(td))
+	}
+	if len(rc.RetryableStatus) > 0 {
+		opts = append(opts, retry.RetryOnStatus(rc.RetryableStatus...))
+	}
+	if len(rc.RetryableCodes) > 0 {
+		cs := make([]codes.Code, 0, len(rc.RetryableCodes))
+		for _, name := range rc.RetryableCodes {
+			var c codes.Code
+			if err = c.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("client retry policy, retryableCodes:%s", name))
+			}
+			cs = append(cs, c)
+		}
+		opts = append(opts, retry.RetryOnCodes(cs...))
+	}
+	if rc.NonIdempotent {
+		opts = append(opts, retry.RetryNonIdempotent())
+	}
+	if len(rc.IdempotentMethods) > 0 {
+		opts = append(opts, retry.IdempotentMethods(rc.IdempotentMethods...))
+	}
+	ratio, minPerSec := 0.1, 10
+	if rc.BudgetRatio != 0 {
+		ratio = rc.BudgetRatio
+	}
+	if rc.BudgetMinPerSec > 0 {
+		minPerSec = rc.BudgetMinPerSec
+	}
+	if ratio > 0 {
+		opts = append(opts, retry.WithBudget(retry.NewBudget(ratio, minPerSec)))
+	}
+	return retry.New(opts...), nil
+}
+
+// newHedgePolicy 按配置生成对冲策略 未配置时返回nil 每个下游独立的对冲预算
+func newHedgePolicy(cfg *config.ClientConfig) (*hedge.Policy, error) {
+	hc := cfg.Hedge
+	if hc == nil {
+		return nil, nil
+	}
+	var opts []hedge.Option
+	if hc.MaxAttempts > 0 {
+		opts = append(opts, hedge.MaxAttempts(hc.MaxAttempts))
+	}
+	if hc.Delay != "" {
+		td, err := time.ParseDuration(hc.Delay)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("client hedge, delay:%s", hc.Delay))
+		}
+		opts = append(opts, hedge.Delay(td))
+	}
+	if hc.Percentile > 0 {
+		opts = append(opts, hedge.Percentile(hc.Percentile))
+	}
+	if hc.MinSamples > 0 {
+		opts = append(opts, hedge.MinSamples(hc.MinSamples))
+	}
+	if len(hc.IdempotentMethods) > 0 {
+		opts = append(opts, hedge.IdempotentMethods(hc.IdempotentMethods...))
+	}
+	ratio, minPerSec := 0.05, 5
+	if hc.BudgetRatio != 0 {
+		ratio = hc.BudgetRatio
+	}
+	if hc.BudgetMinPerSec > 0 {
+		minPerSec = hc.BudgetMinPerSec
+	}
+	if ratio > 0 {
+		opts = append(opts, hedge.WithBudget(retry.NewBudget(ratio, minPerSec)))
+	}
+	return hedge.New(opts...), nil
+}
+
+// newBreakerGroup 按配置生成熔断器组 nacos配置变更时更新阈值
+func newBreakerGroup(fullKey string, name string, proto string, cfg *config.ClientConfig) (*breaker.Group, error) {
+	bc, err := newBreakerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	g := breaker.NewGroup(fullKey, bc)
+	watchClientConfig(fullKey, name, proto, func(cc *config.ClientConfig) error {
+		bc, err := newBreakerConfig(cc)
+		if err != nil {
+			return err
+		}
+		g.Update(bc)
+		return nil
+	})
+	return g, nil
+}
+
+// newOutlierDetector 按配置生成异常节点摘除 nacos配置变更时更新阈值
+func newOutlierDetector(fullKey string, name string, proto string, cfg *config.ClientConfig) (*outlier.Detector, error) {
+	oc, err := newOutlierConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	d := outlier.NewDetector(fullKey, oc, logger.GetLogger())
+	watchClientConfig(fullKey, name, proto, func(cc *config.ClientConfig) error {
+		oc, err := newOutlierConfig(cc)
+		if err != nil {
+			return err
+		}
+		d.Update(oc)
+		return nil
+	})
+	return d, nil
+}
+
+// watchClientConfig 配置变更后使用新的下游配置回调
+func watchClientConfig(fullKey string, name string, proto string, fn func(cc *config.ClientConfig) error) {
+	app.Router().OnConfigChange(func(sc *config.ServiceConfig) {
+		_, cc := sc.GetClient(name, proto)
+		if cc == nil {
+			return
+		}
+		if err := fn(cc); err != nil {
+			logger.Error(app.Router().Ctx(), "client %s config change, error:%v", fullKey, err)
+		}
+	})
+}
+
+// newBreakerConfig 未配置熔断时不熔断
+func newBreakerConfig(cfg *config.ClientConfig) (breaker.Config, error) {
+	bc := cfg.Breaker
+	if bc == nil {
+		return breaker.Config{Disabled: true}, nil
+	}
+	c := breaker.Config{
+		Disabled:         bc.Disable,
+		Buckets:          bc.Buckets,
+		MinRequests:      bc.MinRequests,
+		ErrorRate:        bc.ErrorRate,
+		SlowCallRate:     bc.SlowCallRate,
+		HalfOpenRequests: bc.HalfOpenRequests,
+	}
+	var err error
+	if bc.Window != "" {
+		if c.Window, err = time.ParseDuration(bc.Window); err != nil {
+			return c, errors.WithMessage(err, fmt.Sprintf("client breaker, window:%s", bc.Window))
+		}
+	}
+	if bc.SlowCall != "" {
+		if c.SlowCall, err = time.ParseDuration(bc.SlowCall); err != nil {
+			return c, errors.WithMessage(err, fmt.Sprintf("client breaker, slowCall:%s", bc.SlowCall))
+		}
+	}
+	if bc.OpenTimeout != "" {
+		if c.OpenTimeout, err = time.ParseDuration(bc.OpenTimeout); err != nil {
+			return c, errors.WithMessage(err, fmt.Sprintf("client breaker, openTimeout:%s", bc.OpenTimeout))
+		}
+	}
+	return c, nil
+}
+
+// newOutlierConfig 未配置时不摘除
+func newOutlierConfig(cfg *config.ClientConfig) (outlier.Config, error) {
+	oc := cfg.Outlier
+	if oc == nil {
+		return outlier.Config{Disabled: true}, nil
+	}
+	c := outlier.Config{
+		Disabled:            oc.Disable,
+		ConsecutiveFailures: oc.ConsecutiveFailures,
+		MaxEjectionPercent:  oc.MaxEjectionPercent,
+		ErrorRateStdev:      oc.ErrorRateStdev,
+		MinRequests:         oc.MinRequests,
+		MinHosts:            oc.MinHosts,
+	}
+	var err error
+	if oc.Interval != "" {
+		if c.Interval, err = time.ParseDuration(oc.Interval); err != nil {
+			return c, errors.WithMessage(err, fmt.Sprintf("client outlier, interval:%s", oc.Interval))
+		}
+	}
+	if oc.BaseEjectionTime != "" {
+		if c.BaseEjectionTime, err = time.ParseDuration(oc.BaseEjectionTime); err != nil {
+			return c, errors.WithMessage(err, fmt.Sprintf("client outlier, baseEjectionTime:%s", oc.BaseEjectionTime))
+		}
+	}
+	if oc.MaxEjectionTime != "" {
+		if c.MaxEjectionTime, err = time.ParseDuration(oc.MaxEjectionTime); err != nil {
+			return c, errors.WithMessage(err, fmt.Sprintf("client outlier, maxEjectionTime:%s", oc.MaxEjectionTime))
+		}
+	}
 	return c, nil
 }
 
@@ -268,6 +594,7 @@ func InitHttpClient(ctx context.Context, name string, opts ...http.Option) (*htt
 	if err != nil {
 		return nil, err
 	}
+	// 与rpc相同 为包含重试在内的整体超时
 	opts = append(opts, http.WithTimeout(td))
 	if !config.UnUseDiscovery && app.Router().Discovery() != nil {
 		// 开始服务发现
@@ -277,14 +604,43 @@ func InitHttpClient(ctx context.Context, name string, opts ...http.Option) (*htt
 		}
 		opts = append(opts, http.WithWatcher(watcher))
 	}
-	if config.Retry > 0 {
-		opts = append(opts, http.WithRetry(config.Retry))
+	if config.Balancer != "" {
+		opts = append(opts, http.WithBalancerName(config.Balancer))
+	}
+	policy, err := newRetryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, http.WithRetryPolicy(policy))
+	breakers, err := newBreakerGroup(fullKey, name, env.ProtoHttp, config)
+	if err != nil {
+		return nil, err
 	}
+	opts = append(opts, http.WithBreaker(breakers))
+	detector, err := newOutlierDetector(fullKey, name, env.ProtoHttp, config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, http.WithOutlierDetector(detector))
+	hp, err := newHedgePolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	if hp != nil {
+		opts = append(opts, http.WithHedgePolicy(hp))
+	}
+	// 拦截器在重试和对冲之内 每次请求单独记录
+	// 下游为框架服务 解析envelope格式的业务错误
+	opts = append(opts, http.WithErrorDecoders(http.FrameworkErrorDecoder()))
 	opts = append(opts, http.WithInterceptors(
+		http.MetricsInterceptor(name),
+		http.AccessInterceptor(logger.GetAccess(), name),
+		http.BreakerInterceptor(breakers),
 		http.TracingInterceptor(app.Router().Ctx(), app.Router().Tracer()),
+		http.RequestIDInterceptor(),
 	))
 	c := http.NewClient(ctx, opts...)
-	_client.Store(fullKey, c)
+	storeClient(fullKey, c)
 	return c, nil
 }
 
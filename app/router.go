@@ -35,6 +35,10 @@ type router struct {
 	cancel      func()
 	cfgStr      string
 	cfgChangeCh chan struct{}
+	cfgMutex    sync.Mutex
+	cfgWatchers []func(*config.ServiceConfig)
+	stopMutex   sync.Mutex
+	stopHooks   []func()
 
 	info      *registry.Service
 	discovery registry.Discovery
@@ -61,6 +65,44 @@ func (r *router) OnExtraConfigChange() chan struct{} {
 	return r.cfgChangeCh
 }
 
+// OnConfigChange 配置变更回调 参数为重新解析的配置 Config()返回的启动配置不变
+func (r *router) OnConfigChange(fn func(cfg *config.ServiceConfig)) {
+	r.cfgMutex.Lock()
+	defer r.cfgMutex.Unlock()
+	r.cfgWatchers = append(r.cfgWatchers, fn)
+}
+
+func (r *router) notifyConfigChange(cfgStr string) {
+	var cfg config.ServiceConfig
+	if err := config.Parse(cfgStr, &cfg); err != nil {
+		logger.Error(r.baseCtx, "nacos config change, parse error:%v", err)
+		return
+	}
+	r.cfgMutex.Lock()
+	watchers := append([]func(*config.ServiceConfig){}, r.cfgWatchers...)
+	r.cfgMutex.Unlock()
+	for _, fn := range watchers {
+		fn(&cfg)
+	}
+}
+
+// OnShutdown 退出回调 服务停止后按注册的逆序执行 用于关闭客户端等资源
+func (r *router) OnShutdown(fn func()) {
+	r.stopMutex.Lock()
+	defer r.stopMutex.Unlock()
+	r.stopHooks = append(r.stopHooks, fn)
+}
+
+func (r *router) runShutdownHooks() {
+	r.stopMutex.Lock()
+	hooks := r.stopHooks
+	r.stopHooks = nil
+	r.stopMutex.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
 func (r *router) ExtraJsonConfig(v interface{}) error {
 	return json.Unmarshal([]byte(r.cfgStr), v)
 }
@@ -106,7 +148,8 @@ func InitRouter(sd *config.ServiceDefine, opts ...config.Option) {
 		if sd.Namespace == "" || sd.Product == "" || sd.ServiceName == "" {
 			panic("service undefined")
 		}
-		r = &router{cfgChangeCh: make(chan struct{})}
+		// 缓冲1 未读取时合并通知 不阻塞配置监听
+		r = &router{cfgChangeCh: make(chan struct{}, 1)}
 		// 读取配置
 		cli, cfgStr, err := config.Initialize(sd, &r.cfg, opts...)
 		if err != nil {
@@ -167,7 +210,11 @@ func InitRouter(sd *config.ServiceDefine, opts ...config.Option) {
 					case s := <-r.nacosCli.ListenConfig():
 						log.Println("nacos config change, new data:", s)
 						r.cfgStr = s
-						r.cfgChangeCh <- struct{}{}
+						r.notifyConfigChange(s)
+						select {
+						case r.cfgChangeCh <- struct{}{}:
+						default:
+						}
 					}
 				}
 			}()
@@ -252,6 +299,7 @@ func Run() {
 		}
 	})
 	_ = eg.Wait()
+	r.runShutdownHooks()
 }
 
 func Start(stop func()) {
@@ -343,6 +391,7 @@ func Start(stop func()) {
 		}
 	})
 	_ = eg.Wait()
+	r.runShutdownHooks()
 }
 
 // 改变退出次序。先关闭服务发现，然后结束room。最后整体退出
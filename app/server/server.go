@@ -32,11 +32,19 @@ func InitRPCServer(opts ...rpc.ServerOption) *rpc.Server {
 		panic("undefined rpc server port")
 	}
 	opts = append(opts, rpc.UnaryInterceptor(
+		rpc.RequestIDServerUnaryInterceptor(),
 		rpc.RecoverServerInterceptor(logger.GetLogger()),
 		grpc_prometheus.UnaryServerInterceptor,
 		rpc.TracingServerUnaryInterceptor(app.Router().Tracer()),
 		rpc.AccessServerUnaryInterceptor(logger.GetAccess(), !cfg.AccessRequestDisable),
 	))
+	opts = append(opts, rpc.StreamInterceptor(
+		rpc.RequestIDServerStreamInterceptor(),
+		rpc.RecoverServerStreamInterceptor(logger.GetLogger()),
+		grpc_prometheus.StreamServerInterceptor,
+		rpc.TracingServerStreamInterceptor(app.Router().Tracer()),
+		rpc.AccessServerStreamInterceptor(logger.GetAccess()),
+	))
 	opts = append(opts, rpc.Options([]grpc.ServerOption{
 		grpc.MaxRecvMsgSize(1024 * 1024 * 16),
 	}...))
@@ -71,6 +79,8 @@ func InitWebSocketServer(opts ...websocket.Option) *websocket.Engine {
 	srv := websocket.NewServer(opts...)
 	srv.Use(
 		websocket.PanicHandler(logger.GetLogger()),
+		websocket.RequestIDHandler(),
+		websocket.MetricsHandler(),
 		websocket.TracingHandler(app.Router().Tracer()),
 		websocket.LogHandler(logger.GetAccess(), !cfg.AccessRequestDisable),
 	)
@@ -94,6 +104,8 @@ func InitSocketIOServer(opts ...socketio.Option) *socketio.Engine {
 	srv := socketio.NewServer(opts...)
 	srv.Use(
 		socketio.PanicHandler(logger.GetLogger()),
+		socketio.RequestIDHandler(),
+		socketio.MetricsHandler(),
 		socketio.TracingHandler(app.Router().Tracer()),
 		socketio.LogHandler(logger.GetAccess(), !cfg.AccessRequestDisable),
 	)
@@ -116,6 +128,8 @@ func InitHttpServer(opts ...http.Option) *http.Engine {
 	srv := http.New(opts...)
 	srv.Use(
 		http.PanicHandler(logger.GetLogger()),
+		http.RequestIDHandler(),
+		http.MetricsHandler(),
 		http.TracingHandler(app.Router().Tracer()),
 		http.LogHandler(logger.GetAccess(), !cfg.AccessRequestDisable),
 	)
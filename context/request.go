@@ -0,0 +1,81 @@
+package context
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+///////////////////////////////////////////
+// 请求id 不依赖链路追踪采样 用于日志关联
+///////////////////////////////////////////
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// grpc metadata和mq header统一使用小写key
+	_requestIDMetaKey = "x-request-id"
+	_requestIDMaxLen  = 128
+)
+
+type requestIDKey struct{}
+
+// NewRequestID 生成请求id
+func NewRequestID() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// ValidRequestID 上游传入的请求id只接受可见ascii字符 防止日志注入
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > _requestIDMaxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ChildRequestID 长连接内单条消息的请求id <id>-<seq> 超过长度限制时截断id
+func ChildRequestID(id string, seq uint64) string {
+	suffix := "-" + strconv.FormatUint(seq, 10)
+	if len(id)+len(suffix) > _requestIDMaxLen {
+		id = id[:_requestIDMaxLen-len(suffix)]
+	}
+	return id + suffix
+}
+
+func NewRequestIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func FromRequestIDContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestID 获取context中的请求id 不存在时为空
+func RequestID(ctx context.Context) string {
+	id, _ := FromRequestIDContext(ctx)
+	return id
+}
+
+func GetRequestIDMeta(md Metadata) string {
+	return md.Get(_requestIDMetaKey)
+}
+
+func SetRequestIDMeta(md Metadata, id string) {
+	md.MD[_requestIDMetaKey] = []string{id}
+}
+
+func GetRequestIDHttpHeader(h http.Header) string {
+	return h.Get(RequestIDHeader)
+}
+
+func SetRequestIDHttpHeader(h http.Header, id string) {
+	h.Set(RequestIDHeader, id)
+}
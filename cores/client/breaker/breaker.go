@@ -0,0 +1,394 @@
+package breaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+///////////////////////////////////////////
+// http/rpc客户端共用的熔断器
+// 滑动窗口内错误率或慢调用率超过阈值时打开 打开期间快速失败
+// 打开超时后进入半开 放行少量探测请求 全部成功后关闭 任一失败重新打开
+///////////////////////////////////////////
+
+type State int32
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "unknown"
+}
+
+// ErrOpen 熔断打开 可通过errors.Is判断
+var ErrOpen = errors.New("circuit breaker is open")
+
+// _reasonOpen rpc熔断错误的ErrorInfo.Reason 用于还原为*OpenError
+const _reasonOpen = "CIRCUIT_BREAKER_OPEN"
+
+// OpenError 熔断打开时的快速失败错误 Endpoint为空表示下游整体熔断
+type OpenError struct {
+	Name     string
+	Endpoint string
+}
+
+func (e *OpenError) Error() string {
+	if e.Endpoint != "" {
+		return fmt.Sprintf("circuit breaker is open, name:%s, endpoint:%s", e.Name, e.Endpoint)
+	}
+	return fmt.Sprintf("circuit breaker is open, name:%s", e.Name)
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// GRPCStatus 在rpc中返回UNAVAILABLE 携带ErrorInfo
+func (e *OpenError) GRPCStatus() *status.Status {
+	st := status.New(codes.Unavailable, e.Error())
+	if ds, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   _reasonOpen,
+		Metadata: map[string]string{"name": e.Name, "endpoint": e.Endpoint},
+	}); err == nil {
+		return ds
+	}
+	return st
+}
+
+// Convert 将rpc返回的熔断status还原为*OpenError 其余错误原样返回
+func Convert(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrOpen) {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Unavailable {
+		return err
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Reason == _reasonOpen {
+			return &OpenError{Name: info.Metadata["name"], Endpoint: info.Metadata["endpoint"]}
+		}
+	}
+	return err
+}
+
+// Config 熔断阈值 零值字段使用默认值
+type Config struct {
+	// Disabled 不熔断 只放行
+	Disabled bool
+	// Window 统计窗口 默认10s
+	Window time.Duration
+	// Buckets 窗口分桶数 默认10
+	Buckets int
+	// MinRequests 窗口内请求数达到后才计算比例 默认20
+	MinRequests int
+	// ErrorRate 错误率阈值 0-1 默认0.5
+	ErrorRate float64
+	// SlowCall 慢调用耗时 为0时不统计慢调用
+	SlowCall time.Duration
+	// SlowCallRate 慢调用率阈值 0-1 默认1
+	SlowCallRate float64
+	// OpenTimeout 打开后进入半开的等待时间 默认5s
+	OpenTimeout time.Duration
+	// HalfOpenRequests 半开状态放行的探测请求数 默认5
+	HalfOpenRequests int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 10
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.ErrorRate <= 0 {
+		c.ErrorRate = 0.5
+	}
+	if c.SlowCallRate <= 0 {
+		c.SlowCallRate = 1
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 5 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 5
+	}
+	return c
+}
+
+// Done 记录一次放行请求的结果 耗时从Allow开始计算
+type Done func(failed bool)
+
+func noopDone(bool) {}
+
+type Breaker struct {
+	name     string
+	endpoint string
+
+	mu       sync.Mutex
+	cfg      Config
+	state    State
+	openedAt time.Time
+	// generation 状态变化时递增 旧状态下放行的请求结果不再统计
+	generation uint64
+	window     *window
+	probes     int
+	successes  int
+}
+
+func newBreaker(name string, endpoint string, cfg Config) *Breaker {
+	b := &Breaker{
+		name:     name,
+		endpoint: endpoint,
+		cfg:      cfg,
+		window:   newWindow(cfg.Window, cfg.Buckets),
+	}
+	_state.WithLabelValues(name, endpoint).Set(float64(StateClosed))
+	return b
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Available 当前是否可能放行 不占用半开探测名额 用于选择节点时过滤
+func (b *Breaker) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.cfg.Disabled:
+		return true
+	case b.state == StateOpen:
+		return time.Since(b.openedAt) >= b.cfg.OpenTimeout
+	case b.state == StateHalfOpen:
+		return b.probes < b.cfg.HalfOpenRequests
+	}
+	return true
+}
+
+// Allow 是否放行 熔断打开时返回*OpenError 放行后必须调用返回的Done
+func (b *Breaker) Allow() (Done, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cfg.Disabled {
+		return noopDone, nil
+	}
+	now := time.Now()
+	if b.state == StateOpen {
+		if now.Sub(b.openedAt) < b.cfg.OpenTimeout {
+			_rejected.WithLabelValues(b.name, b.endpoint).Inc()
+			return nil, &OpenError{Name: b.name, Endpoint: b.endpoint}
+		}
+		b.setState(StateHalfOpen, now)
+	}
+	if b.state == StateHalfOpen {
+		if b.probes >= b.cfg.HalfOpenRequests {
+			_rejected.WithLabelValues(b.name, b.endpoint).Inc()
+			return nil, &OpenError{Name: b.name, Endpoint: b.endpoint}
+		}
+		b.probes++
+	}
+	gen := b.generation
+	return func(failed bool) {
+		b.record(gen, failed, time.Since(now))
+	}, nil
+}
+
+func (b *Breaker) record(gen uint64, failed bool, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.generation || b.cfg.Disabled {
+		return
+	}
+	now := time.Now()
+	slow := b.cfg.SlowCall > 0 && d >= b.cfg.SlowCall
+	switch b.state {
+	case StateHalfOpen:
+		if failed || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	case StateClosed:
+		b.window.add(now, failed, slow)
+		total, failures, slows := b.window.sum(now)
+		if total < int64(b.cfg.MinRequests) {
+			return
+		}
+		if float64(failures)/float64(total) >= b.cfg.ErrorRate ||
+			(b.cfg.SlowCall > 0 && float64(slows)/float64(total) >= b.cfg.SlowCallRate) {
+			b.setState(StateOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) setState(s State, now time.Time) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	switch s {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.reset()
+	}
+	_state.WithLabelValues(b.name, b.endpoint).Set(float64(s))
+	_transitions.WithLabelValues(b.name, b.endpoint, s.String()).Inc()
+}
+
+func (b *Breaker) update(cfg Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cfg.Window != b.cfg.Window || cfg.Buckets != b.cfg.Buckets {
+		b.window = newWindow(cfg.Window, cfg.Buckets)
+	}
+	b.cfg = cfg
+	// 关闭熔断时立即恢复
+	if cfg.Disabled {
+		b.setState(StateClosed, time.Now())
+	}
+}
+
+///////////////////////////////////////////
+// 熔断器组 同一下游共享配置
+// endpoint为空为下游整体的熔断器 否则为单个节点的熔断器
+///////////////////////////////////////////
+
+type Group struct {
+	name string
+
+	mu       sync.RWMutex
+	cfg      Config
+	breakers map[string]*Breaker
+}
+
+func NewGroup(name string, cfg Config) *Group {
+	registerMetrics()
+	return &Group{
+		name:     name,
+		cfg:      cfg.withDefaults(),
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get 获取熔断器 不存在时创建
+func (g *Group) Get(endpoint string) *Breaker {
+	g.mu.RLock()
+	b, has := g.breakers[endpoint]
+	g.mu.RUnlock()
+	if has {
+		return b
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, has = g.breakers[endpoint]; has {
+		return b
+	}
+	b = newBreaker(g.name, endpoint, g.cfg)
+	g.breakers[endpoint] = b
+	return b
+}
+
+// Update 更新阈值 对已创建的熔断器立即生效
+func (g *Group) Update(cfg Config) {
+	cfg = cfg.withDefaults()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg = cfg
+	for _, b := range g.breakers {
+		b.update(cfg)
+	}
+}
+
+///////////////////////////////////////////
+// 滑动窗口 按时间分桶
+///////////////////////////////////////////
+
+type bucket struct {
+	idx      int64
+	total    int64
+	failures int64
+	slows    int64
+}
+
+type window struct {
+	size    time.Duration
+	buckets []bucket
+}
+
+func newWindow(d time.Duration, n int) *window {
+	size := d / time.Duration(n)
+	if size <= 0 {
+		size = time.Millisecond
+	}
+	return &window{size: size, buckets: make([]bucket, n)}
+}
+
+func (w *window) add(now time.Time, failed bool, slow bool) {
+	idx := now.UnixNano() / int64(w.size)
+	b := &w.buckets[idx%int64(len(w.buckets))]
+	if b.idx != idx {
+		*b = bucket{idx: idx}
+	}
+	b.total++
+	if failed {
+		b.failures++
+	}
+	if slow {
+		b.slows++
+	}
+}
+
+func (w *window) sum(now time.Time) (total int64, failures int64, slows int64) {
+	idx := now.UnixNano() / int64(w.size)
+	for _, b := range w.buckets {
+		if idx-b.idx < int64(len(w.buckets)) {
+			total += b.total
+			failures += b.failures
+			slows += b.slows
+		}
+	}
+	return
+}
+
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
@@ -0,0 +1,34 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+///////////////////////////////////////////
+// 失败判定 业务错误和调用方取消不计入失败
+///////////////////////////////////////////
+
+// HttpFailure 网络错误或5xx
+func HttpFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrOpen) && !errors.Is(err, context.Canceled)
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// RpcFailure 服务不可用/超时/内部错误
+func RpcFailure(err error) bool {
+	if err == nil || errors.Is(Convert(err), ErrOpen) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package breaker
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+///////////////////////////////////////////
+// prometheus指标
+// name为下游名称 endpoint为空表示下游整体
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_state = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "client_breaker",
+		Name:      "state",
+		Help:      "Circuit breaker state, 0 closed, 1 open, 2 half open.",
+	}, []string{"name", "endpoint"})
+
+	_transitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "client_breaker",
+		Name:      "transitions_total",
+		Help:      "Total number of circuit breaker state transitions.",
+	}, []string{"name", "endpoint", "state"})
+
+	_rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "client_breaker",
+		Name:      "rejected_total",
+		Help:      "Total number of requests rejected by circuit breaker.",
+	}, []string{"name", "endpoint"})
+)
+
+func registerMetrics() {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_state, _transitions, _rejected)
+	})
+}
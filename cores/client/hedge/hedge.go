@@ -0,0 +1,258 @@
+package hedge
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/retry"
+)
+
+///////////////////////////////////////////
+// http/rpc客户端共用的对冲请求策略
+// 请求超过等待时间未返回时 向负载均衡选出的其他节点再发送一次 先成功的结果生效 其余请求取消
+// 等待时间为固定值或下游近期耗时的分位数 只对幂等请求生效 对冲次数受预算限制
+///////////////////////////////////////////
+
+type Option func(*Policy)
+
+type Policy struct {
+	attempts          int
+	delay             time.Duration
+	percentile        float64
+	minSamples        int
+	idempotentMethods map[string]bool
+	budget            *retry.Budget
+	latency           *latency
+}
+
+// MaxAttempts 包含首次请求在内的最多请求数 默认2
+func MaxAttempts(n int) Option {
+	return func(p *Policy) {
+		p.attempts = n
+	}
+}
+
+// Delay 发送对冲请求前的等待时间 使用分位数时为样本不足时的等待时间 默认100ms
+func Delay(d time.Duration) Option {
+	return func(p *Policy) {
+		p.delay = d
+	}
+}
+
+// Percentile 使用下游近期成功请求耗时的分位数作为等待时间 例如0.95
+func Percentile(pct float64) Option {
+	return func(p *Policy) {
+		p.percentile = pct
+	}
+}
+
+// MinSamples 使用分位数时的最少样本数 默认100
+func MinSamples(n int) Option {
+	return func(p *Policy) {
+		p.minSamples = n
+	}
+}
+
+// IdempotentMethods 声明幂等的grpc方法 例如/pkg.Service/Get grpc只对声明的方法对冲
+func IdempotentMethods(methods ...string) Option {
+	return func(p *Policy) {
+		for _, m := range methods {
+			p.idempotentMethods[m] = true
+		}
+	}
+}
+
+// WithBudget 对冲预算 同一下游共用 与重试预算分开
+func WithBudget(b *retry.Budget) Option {
+	return func(p *Policy) {
+		p.budget = b
+	}
+}
+
+func New(opts ...Option) *Policy {
+	p := &Policy{
+		attempts:          2,
+		delay:             100 * time.Millisecond,
+		minSamples:        100,
+		idempotentMethods: make(map[string]bool),
+		latency:           newLatency(_samples),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *Policy) MaxAttempts() int {
+	return p.attempts
+}
+
+// Delay 发送下一次对冲请求前的等待时间
+func (p *Policy) Delay() time.Duration {
+	if p.percentile <= 0 {
+		return p.delay
+	}
+	if d, ok := p.latency.percentile(p.percentile, p.minSamples); ok {
+		return d
+	}
+	return p.delay
+}
+
+// Observe 记录成功请求的耗时 用于计算分位数
+func (p *Policy) Observe(d time.Duration) {
+	if p.percentile > 0 {
+		p.latency.add(d)
+	}
+}
+
+// HttpAllowed http方法是否允许对冲 只对冲GET/HEAD/OPTIONS/TRACE/PUT/DELETE
+func (p *Policy) HttpAllowed(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// RpcAllowed grpc方法是否允许对冲
+func (p *Policy) RpcAllowed(method string) bool {
+	return p.idempotentMethods[method]
+}
+
+// Deposit 每次调用(非对冲)存入预算
+func (p *Policy) Deposit() {
+	if p.budget != nil {
+		p.budget.Deposit()
+	}
+}
+
+// Withdraw 对冲前从预算中扣除 预算不足时返回false
+func (p *Policy) Withdraw() bool {
+	if p.budget == nil {
+		return true
+	}
+	return p.budget.Withdraw()
+}
+
+///////////////////////////////////////////
+// 同一请求各次对冲共享的状态 通过context传递给负载均衡
+// 负载均衡选择节点时跳过已选择过的节点
+///////////////////////////////////////////
+
+type Tried struct {
+	mu    sync.Mutex
+	addrs map[string]bool
+}
+
+func NewTried() *Tried {
+	return &Tried{addrs: make(map[string]bool)}
+}
+
+// Add 记录已选择的节点
+func (t *Tried) Add(addr string) {
+	t.mu.Lock()
+	t.addrs[addr] = true
+	t.mu.Unlock()
+}
+
+// Has 节点是否已被之前的请求选择
+func (t *Tried) Has(addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.addrs[addr]
+}
+
+type hedgeKey struct{}
+
+type hedgeValue struct {
+	index int
+	tried *Tried
+}
+
+// NewContext index为第几次对冲 首次请求为0
+func NewContext(ctx context.Context, index int, tried *Tried) context.Context {
+	return context.WithValue(ctx, hedgeKey{}, hedgeValue{index: index, tried: tried})
+}
+
+// FromContext 未经过对冲时返回nil
+func FromContext(ctx context.Context) *Tried {
+	v, _ := ctx.Value(hedgeKey{}).(hedgeValue)
+	return v.tried
+}
+
+// Index 第几次对冲 首次请求或未经过对冲时为0
+func Index(ctx context.Context) int {
+	v, _ := ctx.Value(hedgeKey{}).(hedgeValue)
+	return v.index
+}
+
+///////////////////////////////////////////
+// 近期耗时样本 环形缓冲 分位数缓存一段时间后重新计算
+///////////////////////////////////////////
+
+const (
+	_samples = 512
+	// _recompute 新增样本数达到后重新计算分位数
+	_recompute = 32
+)
+
+type latency struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+	added   int
+	cached  map[float64]time.Duration
+}
+
+func newLatency(n int) *latency {
+	return &latency{samples: make([]time.Duration, n), cached: make(map[float64]time.Duration)}
+}
+
+func (l *latency) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples[l.next] = d
+	l.next++
+	if l.next == len(l.samples) {
+		l.next, l.full = 0, true
+	}
+	l.added++
+	if l.added >= _recompute {
+		l.added = 0
+		l.cached = make(map[float64]time.Duration)
+	}
+}
+
+func (l *latency) percentile(pct float64, minSamples int) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.samples)
+	}
+	if n == 0 || n < minSamples {
+		return 0, false
+	}
+	if d, has := l.cached[pct]; has {
+		return d, true
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, l.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	idx := int(float64(n)*pct+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= n {
+		idx = n - 1
+	}
+	l.cached[pct] = sorted[idx]
+	return sorted[idx], true
+}
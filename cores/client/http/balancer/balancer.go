@@ -3,14 +3,92 @@ package balancer
 import (
 	"context"
 
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
 	"github.com/curry-mz/sagittarius-golang/cores/registry"
+
+	"github.com/pkg/errors"
+)
+
+// 负载均衡策略名称
+const (
+	Random             = "random"
+	RoundRobin         = "round_robin"
+	WeightedRoundRobin = "weighted_round_robin"
+	LeastRequest       = "least_request"
+	P2C                = "p2c"
+	ConsistentHash     = "consistent_hash"
 )
 
+var ErrNoAvailable = errors.New("no_available_node")
+
 type Balancer interface {
 	Pick(context.Context) (*registry.Service, error)
 	Update(context.Context, []*registry.Service)
 }
 
+// DoneBalancer 需要请求结果的负载均衡 例如最少请求数/延迟 请求结束后调用done 失败判断与熔断一致
+type DoneBalancer interface {
+	Balancer
+	PickDone(context.Context) (*registry.Service, func(failed bool), error)
+}
+
 type Builder interface {
 	Build() Balancer
 }
+
+// Filter 选择节点时过滤 返回false的节点不参与选择 例如熔断中的节点
+type Filter func(*registry.Service) bool
+
+type filterKey struct{}
+
+func NewFilterContext(ctx context.Context, f Filter) context.Context {
+	return context.WithValue(ctx, filterKey{}, f)
+}
+
+func FromFilterContext(ctx context.Context) (Filter, bool) {
+	f, ok := ctx.Value(filterKey{}).(Filter)
+	return f, ok && f != nil
+}
+
+// Available 过滤后的节点 全部被过滤时返回原节点 由调用方处理
+func Available(ctx context.Context, nodes []*registry.Service) []*registry.Service {
+	f, ok := FromFilterContext(ctx)
+	if !ok {
+		return nodes
+	}
+	available := make([]*registry.Service, 0, len(nodes))
+	for _, n := range nodes {
+		if f(n) {
+			available = append(available, n)
+		}
+	}
+	if len(available) == 0 {
+		return nodes
+	}
+	return available
+}
+
+type hashKey struct{}
+
+// NewHashKeyContext 一致性哈希使用的key
+func NewHashKeyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+func FromHashKeyContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKey{}).(string)
+	return key, ok && key != ""
+}
+
+// DefaultWeight 未配置权重时的默认值
+const DefaultWeight = loadbalance.DefaultWeight
+
+// Weight 从服务发现元数据读取权重 缺失或非法时使用默认值 0表示不参与加权选择
+func Weight(node *registry.Service, key string) int {
+	return loadbalance.ParseWeight(node.Metadata[key])
+}
+
+// Host 节点http地址 作为节点标识
+func Host(node *registry.Service) string {
+	return node.Hosts["http"]
+}
@@ -0,0 +1,85 @@
+package consistenthash
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
+	"github.com/curry-mz/sagittarius-golang/cores/registry"
+)
+
+type Option func(o *options)
+
+type options struct {
+	replicas int
+}
+
+// WithReplicas 每个节点的虚拟节点数 默认160
+func WithReplicas(n int) Option {
+	return func(o *options) {
+		o.replicas = n
+	}
+}
+
+// Balancer 一致性哈希 key通过balancer.NewHashKeyContext设置 未设置时随机
+// 命中的节点被过滤时顺时针选择下一个节点
+type Balancer struct {
+	replicas int
+
+	mu    sync.RWMutex
+	nodes []*registry.Service
+	ring  *loadbalance.Ring
+}
+
+func (b *Balancer) Pick(ctx context.Context) (*registry.Service, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.nodes) == 0 {
+		return nil, balancer.ErrNoAvailable
+	}
+	key, ok := balancer.FromHashKeyContext(ctx)
+	if !ok {
+		nodes := balancer.Available(ctx, b.nodes)
+		return nodes[rand.Intn(len(nodes))], nil
+	}
+	var available func(i int) bool
+	if f, filtered := balancer.FromFilterContext(ctx); filtered {
+		available = func(i int) bool {
+			return f(b.nodes[i])
+		}
+	}
+	return b.nodes[b.ring.Get(key, available)], nil
+}
+
+func (b *Balancer) Update(_ context.Context, service []*registry.Service) {
+	if len(service) == 0 {
+		return
+	}
+	hosts := make([]string, 0, len(service))
+	for _, s := range service {
+		hosts = append(hosts, balancer.Host(s))
+	}
+	ring := loadbalance.NewRing(hosts, b.replicas)
+	b.mu.Lock()
+	b.nodes = service
+	b.ring = ring
+	b.mu.Unlock()
+}
+
+type Builder struct {
+	opts options
+}
+
+func (b *Builder) Build() balancer.Balancer {
+	return &Balancer{replicas: b.opts.replicas}
+}
+
+func NewBuilder(opts ...Option) balancer.Builder {
+	option := options{replicas: loadbalance.DefaultReplicas}
+	for _, opt := range opts {
+		opt(&option)
+	}
+	return &Builder{opts: option}
+}
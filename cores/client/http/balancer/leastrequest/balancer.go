@@ -0,0 +1,93 @@
+package leastrequest
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
+	"github.com/curry-mz/sagittarius-golang/cores/registry"
+)
+
+type Option func(o *options)
+
+type options struct{}
+
+// Balancer 最少进行中请求数 相同时随机
+type Balancer struct {
+	mu    sync.RWMutex
+	nodes []*registry.Service
+	loads loadbalance.Loads
+}
+
+// Pick 只选择节点 不计入进行中请求数
+func (b *Balancer) Pick(ctx context.Context) (*registry.Service, error) {
+	node, _, err := b.pick(ctx)
+	return node, err
+}
+
+// PickDone 选择节点并计入进行中请求数 请求结束后需调用返回的函数
+func (b *Balancer) PickDone(ctx context.Context) (*registry.Service, func(failed bool), error) {
+	node, load, err := b.pick(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, load.Start(), nil
+}
+
+func (b *Balancer) pick(ctx context.Context) (*registry.Service, *loadbalance.Load, error) {
+	b.mu.RLock()
+	nodes := balancer.Available(ctx, b.nodes)
+	b.mu.RUnlock()
+	if len(nodes) == 0 {
+		return nil, nil, balancer.ErrNoAvailable
+	}
+	var (
+		best     *registry.Service
+		bestLoad *loadbalance.Load
+		ties     int
+	)
+	for _, n := range nodes {
+		l := b.loads.Get(balancer.Host(n))
+		switch {
+		case best == nil || l.Inflight() < bestLoad.Inflight():
+			best, bestLoad, ties = n, l, 1
+		case l.Inflight() == bestLoad.Inflight():
+			// 蓄水池抽样 相同请求数的节点等概率选择
+			ties++
+			if rand.Intn(ties) == 0 {
+				best, bestLoad = n, l
+			}
+		}
+	}
+	return best, bestLoad, nil
+}
+
+func (b *Balancer) Update(_ context.Context, service []*registry.Service) {
+	if len(service) == 0 {
+		return
+	}
+	hosts := make(map[string]bool, len(service))
+	for _, s := range service {
+		hosts[balancer.Host(s)] = true
+	}
+	b.mu.Lock()
+	b.nodes = service
+	b.mu.Unlock()
+	b.loads.Retain(hosts)
+}
+
+type Builder struct{}
+
+func (b *Builder) Build() balancer.Balancer {
+	return &Balancer{}
+}
+
+func NewBuilder(opts ...Option) balancer.Builder {
+	var option options
+	for _, opt := range opts {
+		opt(&option)
+	}
+	return &Builder{}
+}
@@ -0,0 +1,38 @@
+package leastrequest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/registry"
+)
+
+func TestPickInflight(t *testing.T) {
+	b := NewBuilder().Build().(*Balancer)
+	b.Update(context.Background(), []*registry.Service{
+		{Hosts: map[string]string{"http": "127.0.0.1:8001"}},
+		{Hosts: map[string]string{"http": "127.0.0.1:8002"}},
+	})
+	for i := 0; i < 100; i++ {
+		if _, err := b.Pick(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, n := range b.nodes {
+		if in := b.loads.Get(balancer.Host(n)).Inflight(); in != 0 {
+			t.Fatalf("%s inflight want 0, got %d", balancer.Host(n), in)
+		}
+	}
+	// PickDone结束后归零
+	_, done, err := b.PickDone(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(false)
+	for _, n := range b.nodes {
+		if in := b.loads.Get(balancer.Host(n)).Inflight(); in != 0 {
+			t.Fatalf("%s inflight want 0 after done, got %d", balancer.Host(n), in)
+		}
+	}
+}
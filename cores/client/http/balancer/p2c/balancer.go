@@ -0,0 +1,83 @@
+package p2c
+
+import (
+	"context"
+	"sync"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
+	"github.com/curry-mz/sagittarius-golang/cores/registry"
+)
+
+type Option func(o *options)
+
+type options struct{}
+
+// Balancer 随机选两个节点 取延迟EWMA*(进行中请求数+1)较小的
+type Balancer struct {
+	mu    sync.RWMutex
+	nodes []*registry.Service
+	loads loadbalance.Loads
+}
+
+// Pick 只选择节点 不计入进行中请求数和延迟
+func (b *Balancer) Pick(ctx context.Context) (*registry.Service, error) {
+	node, _, err := b.pick(ctx)
+	return node, err
+}
+
+// PickDone 选择节点并计入进行中请求数 请求结束后需调用返回的函数
+func (b *Balancer) PickDone(ctx context.Context) (*registry.Service, func(failed bool), error) {
+	node, load, err := b.pick(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, load.Start(), nil
+}
+
+func (b *Balancer) pick(ctx context.Context) (*registry.Service, *loadbalance.Load, error) {
+	b.mu.RLock()
+	nodes := balancer.Available(ctx, b.nodes)
+	b.mu.RUnlock()
+	switch len(nodes) {
+	case 0:
+		return nil, nil, balancer.ErrNoAvailable
+	case 1:
+		return nodes[0], b.loads.Get(balancer.Host(nodes[0])), nil
+	}
+	i, j := loadbalance.Pair(len(nodes))
+	a, c := nodes[i], nodes[j]
+	la, lc := b.loads.Get(balancer.Host(a)), b.loads.Get(balancer.Host(c))
+	if lc.Score() < la.Score() {
+		a, la = c, lc
+	}
+	return a, la, nil
+}
+
+func (b *Balancer) Update(_ context.Context, service []*registry.Service) {
+	if len(service) == 0 {
+		return
+	}
+	hosts := make(map[string]bool, len(service))
+	for _, s := range service {
+		hosts[balancer.Host(s)] = true
+	}
+	b.mu.Lock()
+	b.nodes = service
+	b.mu.Unlock()
+	b.loads.Retain(hosts)
+}
+
+type Builder struct{}
+
+func (b *Builder) Build() balancer.Balancer {
+	return &Balancer{}
+}
+
+func NewBuilder(opts ...Option) balancer.Builder {
+	var option options
+	for _, opt := range opts {
+		opt(&option)
+	}
+	return &Builder{}
+}
@@ -3,36 +3,41 @@ package random
 import (
 	"context"
 	"math/rand"
+	"sync"
 
 	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
 	"github.com/curry-mz/sagittarius-golang/cores/registry"
-
-	"github.com/pkg/errors"
 )
 
-var ErrNoAvailable = errors.New("no_available_node")
+var ErrNoAvailable = balancer.ErrNoAvailable
 
 type Option func(o *options)
 
 type options struct{}
 
 type Balancer struct {
+	mu    sync.RWMutex
 	nodes []*registry.Service
 }
 
-func (b *Balancer) Pick(_ context.Context) (*registry.Service, error) {
-	if len(b.nodes) == 0 {
+func (b *Balancer) Pick(ctx context.Context) (*registry.Service, error) {
+	b.mu.RLock()
+	nodes := balancer.Available(ctx, b.nodes)
+	b.mu.RUnlock()
+	if len(nodes) == 0 {
 		return nil, ErrNoAvailable
 	}
-	cur := rand.Intn(len(b.nodes))
-	return b.nodes[cur], nil
+	cur := rand.Intn(len(nodes))
+	return nodes[cur], nil
 }
 
 func (b *Balancer) Update(_ context.Context, service []*registry.Service) {
 	if len(service) == 0 {
 		return
 	}
+	b.mu.Lock()
 	b.nodes = service
+	b.mu.Unlock()
 }
 
 type Builder struct{}
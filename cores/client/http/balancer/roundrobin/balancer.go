@@ -0,0 +1,57 @@
+package roundrobin
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/registry"
+)
+
+type Option func(o *options)
+
+type options struct{}
+
+// Balancer 轮询
+type Balancer struct {
+	mu    sync.RWMutex
+	nodes []*registry.Service
+	next  uint32
+}
+
+func (b *Balancer) Pick(ctx context.Context) (*registry.Service, error) {
+	b.mu.RLock()
+	nodes := balancer.Available(ctx, b.nodes)
+	b.mu.RUnlock()
+	if len(nodes) == 0 {
+		return nil, balancer.ErrNoAvailable
+	}
+	cur := atomic.AddUint32(&b.next, 1)
+	return nodes[cur%uint32(len(nodes))], nil
+}
+
+func (b *Balancer) Update(_ context.Context, service []*registry.Service) {
+	if len(service) == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.nodes = service
+	b.mu.Unlock()
+}
+
+type Builder struct{}
+
+func (b *Builder) Build() balancer.Balancer {
+	// 随机起点 避免所有客户端同时请求同一节点
+	return &Balancer{next: rand.Uint32()}
+}
+
+func NewBuilder(opts ...Option) balancer.Builder {
+	var option options
+	for _, opt := range opts {
+		opt(&option)
+	}
+	return &Builder{}
+}
@@ -0,0 +1,92 @@
+package weighted
+
+import (
+	"context"
+	"sync"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
+	"github.com/curry-mz/sagittarius-golang/cores/registry"
+)
+
+type Option func(o *options)
+
+type options struct {
+	weightKey string
+}
+
+// WithWeightKey 元数据中权重的key 默认weight
+func WithWeightKey(key string) Option {
+	return func(o *options) {
+		o.weightKey = key
+	}
+}
+
+// Balancer 平滑加权轮询 权重来自服务发现元数据
+type Balancer struct {
+	weightKey string
+
+	mu      sync.Mutex
+	nodes   []*registry.Service
+	weights []int
+	smooth  *loadbalance.Smooth
+}
+
+func (b *Balancer) Pick(ctx context.Context) (*registry.Service, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var available func(i int) bool
+	if f, filtered := balancer.FromFilterContext(ctx); filtered {
+		available = func(i int) bool {
+			return f(b.nodes[i])
+		}
+	}
+	idx := -1
+	if b.smooth != nil {
+		idx = b.smooth.Next(b.weights, available)
+	}
+	if idx < 0 {
+		return nil, balancer.ErrNoAvailable
+	}
+	return b.nodes[idx], nil
+}
+
+func (b *Balancer) Update(_ context.Context, service []*registry.Service) {
+	if len(service) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old := make(map[string]int, len(b.nodes))
+	for i, n := range b.nodes {
+		old[balancer.Host(n)] = i
+	}
+	weights := make([]int, 0, len(service))
+	for _, s := range service {
+		weights = append(weights, balancer.Weight(s, b.weightKey))
+	}
+	b.smooth = loadbalance.NewSmooth(len(service), b.smooth, func(i int) int {
+		if j, has := old[balancer.Host(service[i])]; has {
+			return j
+		}
+		return -1
+	})
+	b.nodes = service
+	b.weights = weights
+}
+
+type Builder struct {
+	opts options
+}
+
+func (b *Builder) Build() balancer.Balancer {
+	return &Balancer{weightKey: b.opts.weightKey}
+}
+
+func NewBuilder(opts ...Option) balancer.Builder {
+	option := options{weightKey: loadbalance.WeightKey}
+	for _, opt := range opts {
+		opt(&option)
+	}
+	return &Builder{opts: option}
+}
@@ -3,16 +3,27 @@ package http
 import (
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
 	_binders = map[string]IBinder{
-		"application/json": newJsonBinder(),
-		"application/xml":  newXmlBinder(),
-		"text/plain":       newTextBinder(),
+		"application/json":         newJsonBinder(),
+		"application/problem+json": newJsonBinder(),
+		"application/xml":          newXmlBinder(),
+		"application/problem+xml":  newXmlBinder(),
+		"text/plain":               newTextBinder(),
+		"application/x-protobuf":   newProtoBinder(),
+		"application/protobuf":     newProtoBinder(),
 	}
 )
 
+var _protojsonUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
 type IBinder interface {
 	Marshal(v interface{}) ([]byte, error)
 	Unmarshal(body []byte, v interface{}) error
@@ -24,11 +35,19 @@ func newJsonBinder() *JsonBinder {
 	return &JsonBinder{}
 }
 
+// Unmarshal proto.Message使用protojson解析 忽略未知字段
 func (jb *JsonBinder) Unmarshal(body []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return _protojsonUnmarshal.Unmarshal(body, m)
+	}
 	return json.Unmarshal(body, v)
 }
 
+// Marshal proto.Message使用protojson序列化
 func (jb *JsonBinder) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.Marshal(m)
+	}
 	return json.Marshal(v)
 }
 
@@ -52,10 +71,50 @@ func newTextBinder() *TextBinder {
 	return &TextBinder{}
 }
 
-func (jb *TextBinder) Unmarshal(body []byte, v interface{}) error {
-	return json.Unmarshal(body, v)
+// Unmarshal 支持*string和*[]byte
+func (tb *TextBinder) Unmarshal(body []byte, v interface{}) error {
+	switch t := v.(type) {
+	case *string:
+		*t = string(body)
+	case *[]byte:
+		*t = append((*t)[:0], body...)
+	default:
+		return errors.Errorf("text bind value must be *string or *[]byte, got %T", v)
+	}
+	return nil
 }
 
-func (jb *TextBinder) Marshal(v interface{}) ([]byte, error) {
-	return json.Marshal(v)
+// Marshal 支持string/[]byte/fmt.Stringer
+func (tb *TextBinder) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case string:
+		return []byte(t), nil
+	case []byte:
+		return t, nil
+	case fmt.Stringer:
+		return []byte(t.String()), nil
+	}
+	return nil, errors.Errorf("text body must be string, []byte or fmt.Stringer, got %T", v)
+}
+
+type ProtoBinder struct{}
+
+func newProtoBinder() *ProtoBinder {
+	return &ProtoBinder{}
+}
+
+func (pb *ProtoBinder) Unmarshal(body []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("protobuf bind value must be proto.Message")
+	}
+	return proto.Unmarshal(body, m)
+}
+
+func (pb *ProtoBinder) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.New("protobuf body must be proto.Message")
+	}
+	return proto.Marshal(m)
 }
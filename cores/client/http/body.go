@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+///////////////////////////////////////////
+// 请求body编码
+// 每次发送(包括重试)都会重新调用编码函数 返回新的reader
+///////////////////////////////////////////
+
+// BodyEncoder 返回body和Content-Type
+type BodyEncoder func() (io.Reader, string, error)
+
+// BinderBody 使用已注册的binder按Content-Type编码
+func BinderBody(contentType string, v interface{}) BodyEncoder {
+	return func() (io.Reader, string, error) {
+		binder, has := _binders[mediaType(contentType)]
+		if !has {
+			return nil, "", errors.New(fmt.Sprintf("content type %s not support", contentType))
+		}
+		bs, err := binder.Marshal(v)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(bs), contentType, nil
+	}
+}
+
+func JsonBody(v interface{}) BodyEncoder {
+	return BinderBody("application/json", v)
+}
+
+func XmlBody(v interface{}) BodyEncoder {
+	return BinderBody("application/xml", v)
+}
+
+func ProtoBody(v interface{}) BodyEncoder {
+	return BinderBody("application/x-protobuf", v)
+}
+
+// RawBody 原始字节 不做任何编码
+func RawBody(contentType string, bs []byte) BodyEncoder {
+	return func() (io.Reader, string, error) {
+		return bytes.NewReader(bs), contentType, nil
+	}
+}
+
+// ReaderBody 流式body open每次发送都会调用 返回的reader实现io.Closer时发送完成后关闭
+func ReaderBody(contentType string, open func() (io.Reader, error)) BodyEncoder {
+	return func() (io.Reader, string, error) {
+		r, err := open()
+		if err != nil {
+			return nil, "", err
+		}
+		return r, contentType, nil
+	}
+}
+
+// FormBody application/x-www-form-urlencoded
+func FormBody(values url.Values) BodyEncoder {
+	return func() (io.Reader, string, error) {
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+}
+
+// FormFile multipart上传的文件
+type FormFile struct {
+	Field       string
+	FileName    string
+	ContentType string
+	// Open 每次发送都会调用 重试时需要能重新读取
+	Open func() (io.ReadCloser, error)
+}
+
+// FileFromPath 从本地路径上传
+func FileFromPath(field string, path string) FormFile {
+	return FormFile{
+		Field:    field,
+		FileName: filepath.Base(path),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+}
+
+// FileFromBytes 上传内存数据
+func FileFromBytes(field string, fileName string, bs []byte) FormFile {
+	return FormFile{
+		Field:    field,
+		FileName: fileName,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(bs)), nil
+		},
+	}
+}
+
+// MultipartBody multipart/form-data 文件通过pipe边读边发 不整体加载到内存
+func MultipartBody(fields url.Values, files ...FormFile) BodyEncoder {
+	return func() (io.Reader, string, error) {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		go func() {
+			pw.CloseWithError(writeMultipart(mw, fields, files))
+		}()
+		return pr, mw.FormDataContentType(), nil
+	}
+}
+
+func writeMultipart(mw *multipart.Writer, fields url.Values, files []FormFile) error {
+	for k, vs := range fields {
+		for _, v := range vs {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range files {
+		if err := writeFormFile(mw, f); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+var _quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeFormFile(mw *multipart.Writer, f FormFile) error {
+	if f.Open == nil {
+		return errors.New(fmt.Sprintf("form file %s has no content", f.Field))
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	ct := f.ContentType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		_quoteEscaper.Replace(f.Field), _quoteEscaper.Replace(f.FileName)))
+	h.Set("Content-Type", ct)
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	return err
+}
@@ -1,35 +1,54 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/curry-mz/sagittarius-golang/cores/client/breaker"
+	"github.com/curry-mz/sagittarius-golang/cores/client/hedge"
 	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer/consistenthash"
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer/leastrequest"
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer/p2c"
 	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer/random"
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer/roundrobin"
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer/weighted"
+	"github.com/curry-mz/sagittarius-golang/cores/client/outlier"
+	"github.com/curry-mz/sagittarius-golang/cores/client/retry"
+	"github.com/curry-mz/sagittarius-golang/cores/compress"
 	"github.com/curry-mz/sagittarius-golang/cores/crypto"
 	"github.com/curry-mz/sagittarius-golang/cores/registry"
 
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 )
 
 type Option func(*clientOptions)
 
 type clientOptions struct {
-	tlsConf      *tls.Config
-	timeout      time.Duration
-	transport    http.RoundTripper
-	eps          []string
-	watcher      registry.Watcher
-	balancerName string
-	interceptors []Interceptor
-	retry        int
+	tlsConf       *tls.Config
+	timeout       time.Duration
+	transport     http.RoundTripper
+	eps           []string
+	watcher       registry.Watcher
+	balancerName  string
+	interceptors  []Interceptor
+	retry         int
+	retryPolicy   *retry.Policy
+	compression   string
+	errorDecoders []ErrorDecoder
+	breakers      *breaker.Group
+	detector      *outlier.Detector
+	hedgePolicy   *hedge.Policy
 }
 
 // WithWatcher 服务发现监听
@@ -39,19 +58,23 @@ func WithWatcher(watcher registry.Watcher) Option {
 	}
 }
 
-// WithBalancerName 负载均衡策略
+// WithBalancerName 负载均衡策略 random/round_robin/weighted_round_robin/least_request/p2c/consistent_hash 默认random
 func WithBalancerName(balancerName string) Option {
 	return func(o *clientOptions) {
 		o.balancerName = balancerName
 	}
 }
 
-func WithTransport(trans *http.Transport) Option {
+// WithTransport 自定义transport 非*http.Transport时WithTLSConfig不生效 测试可使用clienttest包的mock/回放
+func WithTransport(trans http.RoundTripper) Option {
 	return func(o *clientOptions) {
 		o.transport = trans
 	}
 }
 
+// WithTimeout 请求超时 包含重试在内的整体超时 通过context生效 0为不限制 Req.Timeout优先
+// 单次请求超时使用retry.PerAttemptTimeout
+// 读取body的请求包含读取body的时间 Do/Download只限制到收到响应header
 func WithTimeout(d time.Duration) Option {
 	return func(o *clientOptions) {
 		o.timeout = d
@@ -76,19 +99,78 @@ func WithEps(eps ...string) Option {
 	}
 }
 
+// WithRetry 重试次数 使用默认重试策略 只重试幂等请求
 func WithRetry(retry int) Option {
 	return func(o *clientOptions) {
 		o.retry = retry
 	}
 }
 
+// WithRetryPolicy 重试策略 优先于WithRetry
+func WithRetryPolicy(p *retry.Policy) Option {
+	return func(o *clientOptions) {
+		o.retryPolicy = p
+	}
+}
+
+// WithCompression 请求body使用gzip/deflate/br压缩 需服务端支持解码
+func WithCompression(encoding string) Option {
+	return func(o *clientOptions) {
+		o.compression = encoding
+	}
+}
+
+// WithBreaker 节点熔断 选择节点时跳过熔断中的节点 下游整体熔断使用BreakerInterceptor
+func WithBreaker(g *breaker.Group) Option {
+	return func(o *clientOptions) {
+		o.breakers = g
+	}
+}
+
+// WithHedgePolicy 对冲请求 只对幂等方法生效 在每次重试内对冲
+func WithHedgePolicy(p *hedge.Policy) Option {
+	return func(o *clientOptions) {
+		o.hedgePolicy = p
+	}
+}
+
+func newBalancerBuilder(name string) balancer.Builder {
+	switch name {
+	case balancer.RoundRobin:
+		return roundrobin.NewBuilder()
+	case balancer.WeightedRoundRobin:
+		return weighted.NewBuilder()
+	case balancer.LeastRequest:
+		return leastrequest.NewBuilder()
+	case balancer.P2C:
+		return p2c.NewBuilder()
+	case balancer.ConsistentHash:
+		return consistenthash.NewBuilder()
+	default:
+		return random.NewBuilder()
+	}
+}
+
+// WithOutlierDetector 异常节点摘除 连续失败或错误率异常的节点一段时间内不参与负载均衡
+func WithOutlierDetector(d *outlier.Detector) Option {
+	return func(o *clientOptions) {
+		o.detector = d
+	}
+}
+
 type Client struct {
-	httpClient   *http.Client
-	interceptors []Interceptor
-	insecure     bool
-	resolver     *resolver
-	watcher      registry.Watcher
-	retry        int
+	httpClient    *http.Client
+	timeout       time.Duration
+	interceptors  []Interceptor
+	insecure      bool
+	resolver      *resolver
+	watcher       registry.Watcher
+	retryPolicy   *retry.Policy
+	compression   string
+	errorDecoders []ErrorDecoder
+	breakers      *breaker.Group
+	detector      *outlier.Detector
+	hedgePolicy   *hedge.Policy
 }
 
 func NewClient(ctx context.Context, opts ...Option) *Client {
@@ -114,35 +196,42 @@ func NewClient(ctx context.Context, opts ...Option) *Client {
 			}
 		}
 	}
-	var (
-		r *resolver
-	)
-	if options.watcher != nil {
-		var builder balancer.Builder
-		switch options.balancerName {
-		case "random":
-			builder = random.NewBuilder()
-		default:
-			builder = random.NewBuilder()
-		}
-		r, _ = newResolver(ctx, options.watcher, builder, options.eps, insecure)
-	} else {
-		r, _ = newResolver(ctx, nil, random.NewBuilder(), options.eps, insecure)
+	r, _ := newResolver(ctx, options.watcher, newBalancerBuilder(options.balancerName), options.detector, options.eps, insecure)
+	if options.retryPolicy == nil {
+		options.retryPolicy = retry.New(retry.Attempts(options.retry))
 	}
 	c := &Client{
 		insecure: insecure,
+		// http.Client.Timeout包含读取body 会中断流式读取和下载 超时改由context控制
 		httpClient: &http.Client{
-			Timeout:   options.timeout,
 			Transport: options.transport,
 		},
+		timeout:      options.timeout,
 		resolver:     r,
 		watcher:      options.watcher,
 		interceptors: options.interceptors,
-		retry:        options.retry,
+		retryPolicy:  options.retryPolicy,
+		compression:  options.compression,
+		// 自定义解析优先
+		errorDecoders: append(options.errorDecoders, _defaultErrorDecoders...),
+		breakers:      options.breakers,
+		detector:      options.detector,
+		hedgePolicy:   options.hedgePolicy,
 	}
 	return c
 }
 
+// Close 停止服务发现监听 关闭自定义transport的空闲连接 关闭后不应再发送请求
+func (c *Client) Close() error {
+	if t, ok := c.httpClient.Transport.(interface{ CloseIdleConnections() }); ok && c.httpClient.Transport != http.DefaultTransport {
+		t.CloseIdleConnections()
+	}
+	if c.resolver != nil {
+		return c.resolver.Close()
+	}
+	return nil
+}
+
 type Req struct {
 	ctx        context.Context
 	header     http.Header
@@ -152,6 +241,8 @@ type Req struct {
 	url        string
 	method     string
 	body       interface{}
+	encoder    BodyEncoder
+	timeout    *time.Duration
 }
 
 func Request(ctx context.Context, uri string) *Req {
@@ -189,29 +280,74 @@ func (r *Req) SetHeaders(values map[string]string) *Req {
 	return r
 }
 
-func (r *Req) makeRequest() (*http.Request, error) {
+// HashKey 一致性哈希负载均衡使用的key 相同key请求同一节点
+func (r *Req) HashKey(key string) *Req {
+	if r.ctx == nil {
+		r.ctx = context.Background()
+	}
+	r.ctx = balancer.NewHashKeyContext(r.ctx, key)
+	return r
+}
+
+// Method 请求方法 用于Do
+func (r *Req) Method(method string) *Req {
+	r.method = strings.ToUpper(method)
+	return r
+}
+
+// Body 请求body编码 用于Do Content-Type使用编码返回的类型
+func (r *Req) Body(encoder BodyEncoder) *Req {
+	r.encoder = encoder
+	return r
+}
+
+// Timeout 本次请求的超时 覆盖客户端的WithTimeout 0为不限制 用于大文件下载/SSE等长时间读取
+func (r *Req) Timeout(d time.Duration) *Req {
+	r.timeout = &d
+	return r
+}
+
+// accept 未指定Accept时使用与请求相同的格式
+func (r *Req) accept(mt string) {
+	if r.header.Get("Accept") == "" {
+		r.header.Set("Accept", mt)
+	}
+}
+
+func (r *Req) makeRequest(ctx context.Context, compression string) (*http.Request, error) {
 	var (
 		err    error
 		req    *http.Request
 		reader io.Reader
+		ct     string
 	)
-	if r.body != nil {
+	if reader, ct, err = r.encodeBody(); err != nil {
+		return nil, err
+	}
+	if reader != nil && r.crypto != nil {
 		var bs []byte
-		bs, err = _binders[r.header.Get("Content-Type")].Marshal(r.body)
+		if bs, err = readBody(reader); err != nil {
+			return nil, err
+		}
+		var s string
+		s, err = r.crypto.Encrypt(string(bs))
 		if err != nil {
 			return nil, err
 		}
-		if r.crypto != nil {
-			var s string
-			s, err = r.crypto.Encrypt(string(bs))
-			if err != nil {
-				return nil, err
-			}
-			bs = []byte(s)
+		reader = bytes.NewReader([]byte(s))
+	}
+	// 加密后压缩 服务端先解压再解密
+	if reader != nil && compression != "" {
+		var cr io.Reader
+		if cr, err = compressReader(compression, reader); err != nil {
+			closeReader(reader)
+			return nil, err
 		}
-		reader = bytes.NewReader(bs)
+		reader = cr
 	}
-	if req, err = http.NewRequest(r.method, r.url, reader); err != nil {
+	// 请求携带context 上游的deadline和取消同样生效
+	if req, err = http.NewRequestWithContext(ctx, r.method, r.url, reader); err != nil {
+		closeReader(reader)
 		return nil, err
 	}
 	for k, vs := range r.header {
@@ -222,6 +358,16 @@ func (r *Req) makeRequest() (*http.Request, error) {
 			req.Host = vs[0]
 		}
 	}
+	if ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	if reader != nil && compression != "" {
+		req.Header.Set("Content-Encoding", compression)
+	}
+	// 未指定时接受压缩响应 由do统一解码
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", _acceptEncoding)
+	}
 	q := req.URL.Query()
 	for k, v := range r.queryParam {
 		for _, vv := range v {
@@ -235,6 +381,49 @@ func (r *Req) makeRequest() (*http.Request, error) {
 	return req, nil
 }
 
+// encodeBody Body设置的编码优先 否则按Content-Type使用binder编码
+func (r *Req) encodeBody() (io.Reader, string, error) {
+	if r.encoder != nil {
+		return r.encoder()
+	}
+	if r.body == nil {
+		return nil, "", nil
+	}
+	binder, has := _binders[mediaType(r.header.Get("Content-Type"))]
+	if !has {
+		return nil, "", errors.New(fmt.Sprintf("content type %s not support", r.header.Get("Content-Type")))
+	}
+	bs, err := binder.Marshal(r.body)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(bs), "", nil
+}
+
+// Do 发送任意方法的请求 返回未读取body的响应 非2xx不作为错误 通过Response.Err检查
+func (c *Client) Do(r *Req) (*Response, error) {
+	if r.method == "" {
+		r.method = http.MethodGet
+	}
+	resp, err := c.send(r.ctx, r, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Response: resp, req: r, decoders: c.errorDecoders}, nil
+}
+
+// Download 下载body写入w 非2xx时返回*HTTPError
+func (c *Client) Download(r *Req, w io.Writer) (int64, error) {
+	resp, err := c.Do(r)
+	if err != nil {
+		return 0, err
+	}
+	if !resp.IsSuccess() {
+		return 0, resp.Err()
+	}
+	return resp.SaveTo(w)
+}
+
 func (c *Client) JsonGet(r *Req, respData interface{}) (*http.Response, error) {
 	var (
 		err  error
@@ -242,17 +431,18 @@ func (c *Client) JsonGet(r *Req, respData interface{}) (*http.Response, error) {
 		body []byte
 	)
 	r.header.Set("Content-Type", "application/json")
+	r.accept("application/json")
 	r.method = http.MethodGet
 	// Send request
 	resp, body, err = c.do(r.ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("http code:%d", resp.StatusCode))
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
 	}
 	if respData != nil && len(body) > 0 {
-		err = c.bind(r, body, respData)
+		err = bind(r, resp, body, respData)
 	}
 	return resp, err
 }
@@ -264,6 +454,7 @@ func (c *Client) JsonPost(r *Req, reqBody interface{}, respData interface{}) (*h
 		body []byte
 	)
 	r.header.Set("Content-Type", "application/json")
+	r.accept("application/json")
 	r.method = http.MethodPost
 	r.body = reqBody
 	// Send request
@@ -271,11 +462,11 @@ func (c *Client) JsonPost(r *Req, reqBody interface{}, respData interface{}) (*h
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("http code:%d", resp.StatusCode))
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
 	}
 	if respData != nil && len(body) > 0 {
-		err = c.bind(r, body, respData)
+		err = bind(r, resp, body, respData)
 	}
 	return resp, err
 }
@@ -287,6 +478,7 @@ func (c *Client) TestPost(r *Req, reqBody interface{}, respData interface{}) (*h
 		body []byte
 	)
 	r.header.Set("Content-Type", "text/plain")
+	r.accept("text/plain")
 	r.method = http.MethodPost
 	r.body = reqBody
 	// Send request
@@ -294,11 +486,11 @@ func (c *Client) TestPost(r *Req, reqBody interface{}, respData interface{}) (*h
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("http code:%d", resp.StatusCode))
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
 	}
 	if respData != nil && len(body) > 0 {
-		err = c.bind(r, body, respData)
+		err = bind(r, resp, body, respData)
 	}
 	return resp, err
 }
@@ -310,17 +502,18 @@ func (c *Client) XmlGet(r *Req, respData interface{}) (*http.Response, error) {
 		body []byte
 	)
 	r.header.Set("Content-Type", "application/xml")
+	r.accept("application/xml")
 	r.method = http.MethodGet
 	// Send request
 	resp, body, err = c.do(r.ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("http code:%d", resp.StatusCode))
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
 	}
 	if respData != nil && len(body) > 0 {
-		err = c.bind(r, body, respData)
+		err = bind(r, resp, body, respData)
 	}
 	return resp, err
 }
@@ -332,6 +525,7 @@ func (c *Client) XmlPost(r *Req, reqBody interface{}, respData interface{}) (*ht
 		body []byte
 	)
 	r.header.Set("Content-Type", "application/xml")
+	r.accept("application/xml")
 	r.method = http.MethodPost
 	r.body = reqBody
 	// Send request
@@ -339,33 +533,64 @@ func (c *Client) XmlPost(r *Req, reqBody interface{}, respData interface{}) (*ht
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("http code:%d", resp.StatusCode))
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
 	}
 	if respData != nil && len(body) > 0 {
-		err = c.bind(r, body, respData)
+		err = bind(r, resp, body, respData)
 	}
 	return resp, err
 }
 
-func (c *Client) do(ctx context.Context, r *Req) (*http.Response, []byte, error) {
+func (c *Client) ProtoGet(r *Req, respData proto.Message) (*http.Response, error) {
 	var (
+		err  error
 		resp *http.Response
+		body []byte
+	)
+	r.header.Set("Content-Type", "application/x-protobuf")
+	r.accept("application/x-protobuf")
+	r.method = http.MethodGet
+	// Send request
+	resp, body, err = c.do(r.ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
+	}
+	if respData != nil && len(body) > 0 {
+		err = bind(r, resp, body, respData)
+	}
+	return resp, err
+}
+
+func (c *Client) ProtoPost(r *Req, reqBody proto.Message, respData proto.Message) (*http.Response, error) {
+	var (
 		err  error
+		resp *http.Response
+		body []byte
 	)
+	r.header.Set("Content-Type", "application/x-protobuf")
+	r.accept("application/x-protobuf")
+	r.method = http.MethodPost
+	r.body = reqBody
 	// Send request
-	for att := 0; att <= c.retry; att++ {
-		var req *http.Request
-		req, err = r.makeRequest()
-		if err != nil {
-			continue
-		}
-		resp, err = doInterceptors(ctx, c, req)
-		if err != nil {
-			continue
-		}
-		break
+	resp, body, err = c.do(r.ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.checkResponse(resp, body); err != nil {
+		return nil, err
 	}
+	if respData != nil && len(body) > 0 {
+		err = bind(r, resp, body, respData)
+	}
+	return resp, err
+}
+
+func (c *Client) do(ctx context.Context, r *Req) (*http.Response, []byte, error) {
+	resp, err := c.send(ctx, r, false)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -380,12 +605,303 @@ func (c *Client) do(ctx context.Context, r *Req) (*http.Response, []byte, error)
 	return resp, body, nil
 }
 
-func (c *Client) bind(req *Req, body []byte, v interface{}) error {
-	accept := req.header.Get("Content-Type")
-	if accept == "" {
-		accept = "application/json"
+// send 发送请求 超时包含全部重试 stream为true时超时只限制到收到响应header 之后读取body不受限制
+func (c *Client) send(ctx context.Context, r *Req, stream bool) (*http.Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	d := c.timeout
+	if r.timeout != nil {
+		d = *r.timeout
 	}
-	if binder, has := _binders[accept]; has {
+	if d <= 0 {
+		return c.retry(ctx, r)
+	}
+	if !stream {
+		tctx, cancel := context.WithTimeout(ctx, d)
+		resp, err := c.retry(tctx, r)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+	// 收到header后停止计时 超时时取消context
+	tctx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(d, cancel)
+	resp, err := c.retry(tctx, r)
+	if !timer.Stop() {
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		cancel()
+		return nil, errors.Wrapf(context.DeadlineExceeded, "%s %s: timeout %v awaiting response headers", r.method, r.url, d)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retry 经过拦截器和服务发现发送请求 body按Content-Encoding流式解码
+// 按重试策略重试 非幂等请求默认不重试
+func (c *Client) retry(ctx context.Context, r *Req) (*http.Response, error) {
+	p := c.retryPolicy
+	p.Deposit()
+	for att := 0; ; att++ {
+		actx, cancel := p.AttemptContext(ctx, att+1)
+		resp, err := c.attempt(actx, r)
+		// 熔断快速失败不重试
+		retryable := (err != nil && !errors.Is(err, breaker.ErrOpen)) || (err == nil && p.RetryableStatus(resp.StatusCode))
+		// 上游deadline到期或取消后不再重试
+		if !retryable || att >= p.MaxAttempts() || ctx.Err() != nil ||
+			!p.HttpAllowed(r.method) || !p.Withdraw() {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			// 单次超时的context在body关闭后释放
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			if err = decompressResponse(resp); err != nil {
+				_ = resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			// 读取少量数据以便复用连接
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			_ = resp.Body.Close()
+		}
+		cancel()
+		if err = p.Wait(ctx, att+1); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt 单次请求 配置对冲且方法幂等时发送对冲请求
+func (c *Client) attempt(ctx context.Context, r *Req) (*http.Response, error) {
+	if c.hedgePolicy != nil && c.hedgePolicy.HttpAllowed(r.method) {
+		return c.hedge(ctx, r)
+	}
+	req, err := r.makeRequest(ctx, c.compression)
+	if err != nil {
+		return nil, err
+	}
+	return doInterceptors(ctx, c, req)
+}
+
+type hedgeResult struct {
+	idx  int
+	resp *http.Response
+	err  error
+}
+
+// hedge 等待时间内未成功时向其他节点发送对冲请求 先成功的结果生效 其余请求取消
+// 网络错误和5xx不算成功 全部失败时返回最后一个结果
+func (c *Client) hedge(ctx context.Context, r *Req) (*http.Response, error) {
+	h := c.hedgePolicy
+	h.Deposit()
+	tried := hedge.NewTried()
+	results := make(chan hedgeResult, h.MaxAttempts())
+	cancels := make([]context.CancelFunc, 0, h.MaxAttempts())
+	launch := func() {
+		idx := len(cancels)
+		actx, cancel := context.WithCancel(hedge.NewContext(ctx, idx, tried))
+		cancels = append(cancels, cancel)
+		req, err := r.makeRequest(actx, c.compression)
+		if err != nil {
+			results <- hedgeResult{idx: idx, err: err}
+			return
+		}
+		go func() {
+			start := time.Now()
+			resp, err := doInterceptors(actx, c, req)
+			if err == nil && resp.StatusCode < http.StatusInternalServerError {
+				h.Observe(time.Since(start))
+			}
+			results <- hedgeResult{idx: idx, resp: resp, err: err}
+		}()
+	}
+	// finish 取消其余请求 后台释放未返回的响应
+	finish := func(res hedgeResult, pending int) (*http.Response, error) {
+		for i, cancel := range cancels {
+			if i != res.idx {
+				cancel()
+			}
+		}
+		if pending > 0 {
+			go func() {
+				for i := 0; i < pending; i++ {
+					if res := <-results; res.resp != nil {
+						_ = res.resp.Body.Close()
+					}
+				}
+			}()
+		}
+		if res.err != nil {
+			cancels[res.idx]()
+			return nil, res.err
+		}
+		res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: cancels[res.idx]}
+		return res.resp, nil
+	}
+	launch()
+	pending := 1
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < h.MaxAttempts() && ctx.Err() == nil && h.Withdraw() {
+				launch()
+				pending++
+				timer.Reset(h.Delay())
+			}
+		case res := <-results:
+			pending--
+			if (res.err == nil && res.resp.StatusCode < http.StatusInternalServerError) || pending == 0 {
+				return finish(res, pending)
+			}
+			// 失败的结果丢弃 等待其余请求
+			if res.resp != nil {
+				_ = res.resp.Body.Close()
+			}
+		}
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+var _acceptEncoding = strings.Join([]string{compress.Brotli, compress.Gzip, compress.Deflate}, ", ")
+
+// compressReader 内存数据直接压缩 保留Content-Length 其余通过pipe流式压缩
+func compressReader(encoding string, r io.Reader) (io.Reader, error) {
+	switch r.(type) {
+	case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+		bs, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if bs, err = compressBody(encoding, bs); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(bs), nil
+	}
+	if !compress.Supported(encoding) {
+		return nil, errors.Wrap(compress.ErrUnsupportedEncoding, encoding)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := compress.NewWriter(encoding, pw, compress.DefaultLevel)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		closeReader(r)
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+func compressBody(encoding string, bs []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := compress.NewWriter(encoding, &buf, compress.DefaultLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(bs); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readBody 读取全部数据 reader实现io.Closer时关闭
+func readBody(r io.Reader) ([]byte, error) {
+	defer closeReader(r)
+	return io.ReadAll(r)
+}
+
+// closeReader 未交给transport的body需要自行关闭 否则pipe写入端会一直阻塞
+func closeReader(r io.Reader) {
+	if rc, ok := r.(io.Closer); ok {
+		_ = rc.Close()
+	}
+}
+
+// decompressResponse 按响应Content-Encoding包装body 解码后移除相关header
+func decompressResponse(resp *http.Response) error {
+	ce := resp.Header.Get("Content-Encoding")
+	if ce == "" || !compress.Supported(ce) || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	resp.Body = &decodeBody{body: resp.Body, encoding: ce}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodeBody 首次读取时创建解码器 空body不报错
+type decodeBody struct {
+	body     io.ReadCloser
+	encoding string
+	r        io.ReadCloser
+	err      error
+}
+
+func (d *decodeBody) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		br := bufio.NewReader(d.body)
+		if _, err := br.Peek(1); err == io.EOF {
+			d.err = io.EOF
+		} else {
+			d.r, d.err = compress.NewReader(d.encoding, br)
+		}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+func (d *decodeBody) Close() error {
+	if d.r != nil {
+		_ = d.r.Close()
+	}
+	return d.body.Close()
+}
+
+// bind 按响应Content-Type解析 缺失时使用请求的Accept
+func bind(req *Req, resp *http.Response, body []byte, v interface{}) error {
+	ct := mediaType(resp.Header.Get("Content-Type"))
+	if _, has := _binders[ct]; !has {
+		ct = mediaType(req.header.Get("Accept"))
+	}
+	if ct == "" {
+		ct = "application/json"
+	}
+	if binder, has := _binders[ct]; has {
 		err := binder.Unmarshal(body, v)
 		if err != nil {
 			return err
@@ -393,3 +909,12 @@ func (c *Client) bind(req *Req, body []byte, v interface{}) error {
 	}
 	return nil
 }
+
+// mediaType 去掉charset等参数 多个值时取第一个
+func mediaType(ct string) string {
+	ct = strings.TrimSpace(strings.Split(ct, ",")[0])
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return ct
+}
@@ -0,0 +1,106 @@
+package clienttest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+///////////////////////////////////////////
+// 录制文件 按扩展名使用json或yaml 其余扩展名使用yaml
+// body为原始数据 非utf8时使用base64编码
+///////////////////////////////////////////
+
+// _base64 body编码
+const _base64 = "base64"
+
+type Cassette struct {
+	Interactions []*Interaction `yaml:"interactions" json:"interactions"`
+}
+
+// Interaction 一次请求和响应
+type Interaction struct {
+	Request  RecordedRequest  `yaml:"request" json:"request"`
+	Response RecordedResponse `yaml:"response" json:"response"`
+}
+
+type RecordedRequest struct {
+	Method   string      `yaml:"method" json:"method"`
+	URL      string      `yaml:"url" json:"url"`
+	Header   http.Header `yaml:"header,omitempty" json:"header,omitempty"`
+	Body     string      `yaml:"body,omitempty" json:"body,omitempty"`
+	Encoding string      `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+}
+
+// RecordedResponse Error不为空时为transport错误
+type RecordedResponse struct {
+	Status   int         `yaml:"status,omitempty" json:"status,omitempty"`
+	Header   http.Header `yaml:"header,omitempty" json:"header,omitempty"`
+	Body     string      `yaml:"body,omitempty" json:"body,omitempty"`
+	Encoding string      `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+	Error    string      `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// LoadCassette 读取录制文件
+func LoadCassette(path string) (*Cassette, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if isJSON(path) {
+		err = json.Unmarshal(bs, &c)
+	} else {
+		err = yaml.Unmarshal(bs, &c)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "clienttest: load cassette "+path)
+	}
+	return &c, nil
+}
+
+// Save 写入录制文件 目录不存在时创建
+func (c *Cassette) Save(path string) error {
+	var (
+		bs  []byte
+		err error
+	)
+	if isJSON(path) {
+		bs, err = json.MarshalIndent(c, "", "  ")
+	} else {
+		bs, err = yaml.Marshal(c)
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, bs, 0644)
+}
+
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// encodeBody 非utf8的数据使用base64
+func encodeBody(bs []byte) (string, string) {
+	if utf8.Valid(bs) {
+		return string(bs), ""
+	}
+	return base64.StdEncoding.EncodeToString(bs), _base64
+}
+
+func decodeRecorded(body string, encoding string) ([]byte, error) {
+	if encoding == _base64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
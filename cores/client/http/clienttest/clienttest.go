@@ -0,0 +1,162 @@
+package clienttest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	gHttp "github.com/curry-mz/sagittarius-golang/cores/client/http"
+	"github.com/curry-mz/sagittarius-golang/cores/compress"
+)
+
+///////////////////////////////////////////
+// 测试http客户端的工具
+// Mock按方法/路径/body返回预设的响应 Recorder录制真实请求到文件 Replayer按录制文件回放
+// 通过gHttp.WithTransport或NewClient使用
+///////////////////////////////////////////
+
+// Host NewClient使用的固定节点
+const Host = "http://clienttest.local"
+
+// NewClient 使用rt作为transport的客户端 未指定节点时使用Host
+func NewClient(ctx context.Context, rt http.RoundTripper, opts ...gHttp.Option) *gHttp.Client {
+	opts = append([]gHttp.Option{gHttp.WithEps(Host)}, opts...)
+	return gHttp.NewClient(ctx, append(opts, gHttp.WithTransport(rt))...)
+}
+
+// MismatchError 没有匹配的预设响应或录制记录 Reasons为每个候选不匹配的原因
+type MismatchError struct {
+	Method  string
+	URL     string
+	Body    string
+	Reasons []string
+}
+
+func (e *MismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "clienttest: no match for %s %s", e.Method, e.URL)
+	if e.Body != "" {
+		fmt.Fprintf(&b, "\n  body: %s", e.Body)
+	}
+	if len(e.Reasons) == 0 {
+		b.WriteString("\n  no candidates")
+	}
+	for _, r := range e.Reasons {
+		fmt.Fprintf(&b, "\n  %s", r)
+	}
+	return b.String()
+}
+
+// _maxBodyLog 错误信息中body的最大长度
+const _maxBodyLog = 512
+
+func newMismatchError(req *http.Request, body []byte, reasons []string) *MismatchError {
+	s := string(body)
+	if len(s) > _maxBodyLog {
+		s = s[:_maxBodyLog] + "..."
+	}
+	return &MismatchError{Method: req.Method, URL: req.URL.String(), Body: s, Reasons: reasons}
+}
+
+// readBody 读取请求body后还原 便于transport继续使用
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	bs, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(bs))
+	return bs, err
+}
+
+// decodeBody 按Content-Encoding解压 用于匹配 解压失败时返回原数据
+func decodeBody(header http.Header, body []byte) []byte {
+	ce := header.Get("Content-Encoding")
+	if ce == "" || len(body) == 0 || !compress.Supported(ce) {
+		return body
+	}
+	r, err := compress.NewReader(ce, bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	defer r.Close()
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return body
+	}
+	return bs
+}
+
+// bodyEqual 两边都是json时按语义比较 其余按字节比较
+func bodyEqual(expected []byte, actual []byte) bool {
+	if bytes.Equal(expected, actual) {
+		return true
+	}
+	var ev, av interface{}
+	if json.Unmarshal(expected, &ev) != nil || json.Unmarshal(actual, &av) != nil {
+		return false
+	}
+	return reflect.DeepEqual(ev, av)
+}
+
+// pathMatch 以*结尾时按前缀匹配
+func pathMatch(pattern string, path string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == path
+}
+
+// queryDiff expected中的参数都需要存在且相同 返回第一个不同的参数
+func queryDiff(expected url.Values, actual url.Values) string {
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !reflect.DeepEqual(expected[k], actual[k]) {
+			return fmt.Sprintf("query %s want %v, got %v", k, expected[k], actual[k])
+		}
+	}
+	return ""
+}
+
+// headerDiff expected中的header都需要存在且相同 返回第一个不同的header
+func headerDiff(expected http.Header, actual http.Header) string {
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !reflect.DeepEqual(expected.Values(k), actual.Values(k)) {
+			return fmt.Sprintf("header %s want %v, got %v", k, expected.Values(k), actual.Values(k))
+		}
+	}
+	return ""
+}
+
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
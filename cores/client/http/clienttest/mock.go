@@ -0,0 +1,263 @@
+package clienttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+///////////////////////////////////////////
+// 可编程的mock transport
+// 按注册顺序匹配第一个满足条件的Route 没有匹配时返回*MismatchError
+///////////////////////////////////////////
+
+// Call 收到的请求
+type Call struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+type Mock struct {
+	mu     sync.Mutex
+	routes []*Route
+	calls  []Call
+}
+
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// On 注册路由 method为空或*时匹配任意方法 path以*结尾时按前缀匹配
+func (m *Mock) On(method string, path string) *Route {
+	r := &Route{
+		mock:   m,
+		method: method,
+		path:   path,
+		status: http.StatusOK,
+	}
+	m.mu.Lock()
+	m.routes = append(m.routes, r)
+	m.mu.Unlock()
+	return r
+}
+
+// Calls 收到的全部请求 包括未匹配的请求
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call{}, m.calls...)
+}
+
+// Pending 设置了Times但调用次数不足的路由
+func (m *Mock) Pending() []*Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []*Route
+	for _, r := range m.routes {
+		if r.times > 0 && r.hits < r.times {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func (m *Mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	// 压缩的请求按解压后的body匹配
+	body = decodeBody(req.Header, body)
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	var (
+		route   *Route
+		reasons []string
+	)
+	for _, r := range m.routes {
+		if reason := r.mismatch(req, body); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", r, reason))
+			continue
+		}
+		r.hits++
+		route = r
+		break
+	}
+	m.mu.Unlock()
+	if route == nil {
+		return nil, newMismatchError(req, body, reasons)
+	}
+	if route.delay > 0 {
+		t := time.NewTimer(route.delay)
+		select {
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		case <-t.C:
+		}
+	}
+	if route.err != nil {
+		return nil, route.err
+	}
+	return newResponse(req, route.status, route.respHeader, route.respBody), nil
+}
+
+///////////////////////////////////////////
+// 路由 匹配条件和响应 在发送请求前设置
+///////////////////////////////////////////
+
+type Route struct {
+	mock   *Mock
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   []byte
+	bodyFn func(body []byte) bool
+	times  int
+	hits   int
+
+	status     int
+	respHeader http.Header
+	respBody   []byte
+	err        error
+	delay      time.Duration
+}
+
+func (r *Route) String() string {
+	method := r.method
+	if method == "" {
+		method = "*"
+	}
+	return fmt.Sprintf("%s %s", method, r.path)
+}
+
+// WithQuery 请求需包含该参数
+func (r *Route) WithQuery(key string, value string) *Route {
+	if r.query == nil {
+		r.query = url.Values{}
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+// WithHeader 请求需包含该header
+func (r *Route) WithHeader(key string, value string) *Route {
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	r.header.Add(key, value)
+	return r
+}
+
+// WithBody 请求body相同 json按语义比较
+func (r *Route) WithBody(body string) *Route {
+	r.body = []byte(body)
+	return r
+}
+
+// WithJSONBody 请求body与v编码后的json语义相同
+func (r *Route) WithJSONBody(v interface{}) *Route {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: marshal json body, error:%v", err))
+	}
+	r.body = bs
+	return r
+}
+
+// MatchBody 自定义body匹配
+func (r *Route) MatchBody(fn func(body []byte) bool) *Route {
+	r.bodyFn = fn
+	return r
+}
+
+// Times 最多匹配n次 超过后继续匹配后面的路由 0为不限制
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Reply 响应状态码和body
+func (r *Route) Reply(status int, body string) *Route {
+	r.status = status
+	r.respBody = []byte(body)
+	return r
+}
+
+// ReplyJSON 响应状态码和json body
+func (r *Route) ReplyJSON(status int, v interface{}) *Route {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: marshal json reply, error:%v", err))
+	}
+	r.status = status
+	r.respBody = bs
+	return r.ReplyHeader("Content-Type", "application/json")
+}
+
+// ReplyHeader 响应header
+func (r *Route) ReplyHeader(key string, value string) *Route {
+	if r.respHeader == nil {
+		r.respHeader = http.Header{}
+	}
+	r.respHeader.Add(key, value)
+	return r
+}
+
+// ReplyError 返回transport错误 例如模拟网络错误
+func (r *Route) ReplyError(err error) *Route {
+	r.err = err
+	return r
+}
+
+// Delay 响应前等待 请求context结束时返回context错误
+func (r *Route) Delay(d time.Duration) *Route {
+	r.delay = d
+	return r
+}
+
+// Hits 已匹配的次数
+func (r *Route) Hits() int {
+	r.mock.mu.Lock()
+	defer r.mock.mu.Unlock()
+	return r.hits
+}
+
+// mismatch 返回不匹配的原因 匹配时返回空
+func (r *Route) mismatch(req *http.Request, body []byte) string {
+	if r.method != "" && r.method != "*" && r.method != req.Method {
+		return fmt.Sprintf("method want %s, got %s", r.method, req.Method)
+	}
+	if !pathMatch(r.path, req.URL.Path) {
+		return fmt.Sprintf("path want %s, got %s", r.path, req.URL.Path)
+	}
+	if d := queryDiff(r.query, req.URL.Query()); d != "" {
+		return d
+	}
+	if d := headerDiff(r.header, req.Header); d != "" {
+		return d
+	}
+	if r.body != nil && !bodyEqual(r.body, body) {
+		return "body not equal"
+	}
+	if r.bodyFn != nil && !r.bodyFn(body) {
+		return "body not matched"
+	}
+	if r.times > 0 && r.hits >= r.times {
+		return fmt.Sprintf("exhausted after %d calls", r.times)
+	}
+	return ""
+}
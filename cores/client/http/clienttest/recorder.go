@@ -0,0 +1,114 @@
+package clienttest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+///////////////////////////////////////////
+// 录制 请求经过真实transport 记录请求和响应 调用Save写入文件
+// 默认隐藏Authorization/Cookie/Set-Cookie的值
+///////////////////////////////////////////
+
+// _redacted 隐藏的header值
+const _redacted = "REDACTED"
+
+type RecorderOption func(*Recorder)
+
+// RecordTransport 真实请求使用的transport 默认http.DefaultTransport
+func RecordTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.transport = rt
+	}
+}
+
+// RecordRedact 额外隐藏的header 例如token
+func RecordRedact(headers ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, h := range headers {
+			r.redact[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+}
+
+type Recorder struct {
+	path      string
+	transport http.RoundTripper
+	redact    map[string]bool
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder path为录制文件路径 .json使用json 其余使用yaml
+func NewRecorder(path string, opts ...RecorderOption) *Recorder {
+	r := &Recorder{
+		path:      path,
+		transport: http.DefaultTransport,
+		redact:    map[string]bool{"Authorization": true, "Cookie": true, "Set-Cookie": true},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	it := &Interaction{}
+	it.Request.Method = req.Method
+	it.Request.URL = req.URL.String()
+	it.Request.Header = r.redactHeader(req.Header)
+	it.Request.Body, it.Request.Encoding = encodeBody(reqBody)
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		it.Response.Error = err.Error()
+		r.add(it)
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	it.Response.Status = resp.StatusCode
+	it.Response.Header = r.redactHeader(resp.Header)
+	it.Response.Body, it.Response.Encoding = encodeBody(respBody)
+	r.add(it)
+	return resp, nil
+}
+
+// Cassette 已录制的内容
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]*Interaction{}, r.cassette.Interactions...)}
+}
+
+// Save 写入录制文件
+func (r *Recorder) Save() error {
+	return r.Cassette().Save(r.path)
+}
+
+func (r *Recorder) add(it *Interaction) {
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	res := h.Clone()
+	for k := range res {
+		if r.redact[k] {
+			res[k] = []string{_redacted}
+		}
+	}
+	return res
+}
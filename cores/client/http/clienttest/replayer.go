@@ -0,0 +1,152 @@
+package clienttest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+///////////////////////////////////////////
+// 回放 按方法/路径/参数/body匹配第一个未使用的录制记录 不比较host
+// 每条记录默认只回放一次 相同请求按录制顺序返回 没有匹配时返回*MismatchError
+///////////////////////////////////////////
+
+type ReplayerOption func(*Replayer)
+
+// ReplayMatchHeaders 额外比较的请求header 被隐藏的header不应设置
+func ReplayMatchHeaders(headers ...string) ReplayerOption {
+	return func(r *Replayer) {
+		r.headers = append(r.headers, headers...)
+	}
+}
+
+// ReplayRepeat 记录可重复回放 相同请求总是返回第一条匹配的记录
+func ReplayRepeat() ReplayerOption {
+	return func(r *Replayer) {
+		r.repeat = true
+	}
+}
+
+type Replayer struct {
+	headers []string
+	repeat  bool
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewReplayer 读取录制文件
+func NewReplayer(path string, opts ...ReplayerOption) (*Replayer, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCassetteReplayer(c, opts...), nil
+}
+
+// NewCassetteReplayer 回放内存中的录制内容 例如Recorder.Cassette()
+func NewCassetteReplayer(c *Cassette, opts ...ReplayerOption) *Replayer {
+	r := &Replayer{
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	var (
+		it      *Interaction
+		reasons []string
+	)
+	for i, candidate := range r.interactions {
+		reason := r.mismatch(candidate, req, body)
+		if reason == "" && r.used[i] && !r.repeat {
+			reason = "already replayed"
+		}
+		if reason != "" {
+			reasons = append(reasons, fmt.Sprintf("#%d %s %s: %s",
+				i, candidate.Request.Method, candidate.Request.URL, reason))
+			continue
+		}
+		r.used[i] = true
+		it = candidate
+		break
+	}
+	r.mu.Unlock()
+	if it == nil {
+		return nil, newMismatchError(req, body, reasons)
+	}
+	if it.Response.Error != "" {
+		return nil, errors.New(it.Response.Error)
+	}
+	respBody, err := decodeRecorded(it.Response.Body, it.Response.Encoding)
+	if err != nil {
+		return nil, errors.WithMessage(err, "clienttest: decode recorded body")
+	}
+	return newResponse(req, it.Response.Status, it.Response.Header, respBody), nil
+}
+
+// Unused 未回放的记录 用于检查请求是否都已发送
+func (r *Replayer) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []*Interaction
+	for i, it := range r.interactions {
+		if !r.used[i] {
+			res = append(res, it)
+		}
+	}
+	return res
+}
+
+// mismatch 返回不匹配的原因 匹配时返回空
+func (r *Replayer) mismatch(it *Interaction, req *http.Request, body []byte) string {
+	if it.Request.Method != req.Method {
+		return fmt.Sprintf("method want %s, got %s", it.Request.Method, req.Method)
+	}
+	u, err := url.Parse(it.Request.URL)
+	if err != nil {
+		return fmt.Sprintf("invalid recorded url, error:%v", err)
+	}
+	if u.Path != req.URL.Path {
+		return fmt.Sprintf("path want %s, got %s", u.Path, req.URL.Path)
+	}
+	// 参数需完全相同
+	expected, actual := u.Query(), req.URL.Query()
+	if d := queryDiff(expected, actual); d != "" {
+		return d
+	}
+	if d := queryDiff(actual, expected); d != "" {
+		return "unexpected " + d
+	}
+	if len(r.headers) > 0 {
+		expectedHeader, actualHeader := http.Header{}, http.Header{}
+		for _, h := range r.headers {
+			expectedHeader[http.CanonicalHeaderKey(h)] = it.Request.Header.Values(h)
+			actualHeader[http.CanonicalHeaderKey(h)] = req.Header.Values(h)
+		}
+		if d := headerDiff(expectedHeader, actualHeader); d != "" {
+			return d
+		}
+	}
+	recorded, err := decodeRecorded(it.Request.Body, it.Request.Encoding)
+	if err != nil {
+		return fmt.Sprintf("invalid recorded body, error:%v", err)
+	}
+	if !bodyEqual(decodeBody(it.Request.Header, recorded), decodeBody(req.Header, body)) {
+		return "body not equal"
+	}
+	return ""
+}
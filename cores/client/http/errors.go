@@ -0,0 +1,275 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+///////////////////////////////////////////
+// 响应错误解析
+// 非2xx或解析出业务错误返回*HTTPError 解析出的*errors.Error可通过errors.Is/As获取
+// 默认只解析problem和google.rpc.Status 框架的envelope格式需通过WithErrorDecoders启用
+///////////////////////////////////////////
+
+// HTTPError 保留状态码和body
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Err 解析出的业务错误 未识别时为nil
+	Err error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("http code:%d, %s", e.StatusCode, e.Err.Error())
+	}
+	return fmt.Sprintf("http code:%d", e.StatusCode)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorDecoder 从响应中解析业务错误 不是错误或无法识别时返回nil
+// 2xx响应同样会调用 用于识别http 200的业务错误
+type ErrorDecoder func(resp *http.Response, body []byte) error
+
+// WithErrorDecoders 自定义错误解析 优先于默认的problem/google.rpc.Status解析
+// 调用本框架服务时使用FrameworkErrorDecoder app/proxy创建的客户端已默认添加
+func WithErrorDecoders(decoders ...ErrorDecoder) Option {
+	return func(o *clientOptions) {
+		o.errorDecoders = append(o.errorDecoders, decoders...)
+	}
+}
+
+// _defaultErrorDecoders 只依据Content-Type识别 不会误判第三方服务的正常响应
+var _defaultErrorDecoders = []ErrorDecoder{
+	ProblemErrorDecoder(),
+	StatusProtoErrorDecoder(),
+}
+
+// checkResponse 非2xx或解析出业务错误时返回*HTTPError
+func (c *Client) checkResponse(resp *http.Response, body []byte) error {
+	return checkResponse(c.errorDecoders, resp, body)
+}
+
+func checkResponse(decoders []ErrorDecoder, resp *http.Response, body []byte) error {
+	var decoded error
+	for _, d := range decoders {
+		if decoded = d(resp, body); decoded != nil {
+			break
+		}
+	}
+	if decoded == nil && isSuccess(resp.StatusCode) {
+		return nil
+	}
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Err:        decoded,
+	}
+}
+
+func isSuccess(code int) bool {
+	return code >= http.StatusOK && code < http.StatusMultipleChoices
+}
+
+// FrameworkErrorDecoder 解析render.NewEnvelope默认字段的响应
+func FrameworkErrorDecoder() ErrorDecoder {
+	return EnvelopeErrorDecoder("status", "message", "details")
+}
+
+// EnvelopeErrorDecoder 解析render.NewEnvelope格式 {"status":1001,"message":"..."}
+// 业务码为数字且信息为字符串时才认为是错误 业务码为0表示成功
+// 第三方服务的{"status":1,"message":"ok"}同样会被识别为错误 只用于确定使用该格式的服务
+func EnvelopeErrorDecoder(statusField string, messageField string, detailsField string) ErrorDecoder {
+	return func(resp *http.Response, body []byte) error {
+		ct := mediaType(resp.Header.Get("Content-Type"))
+		if strings.HasPrefix(ct, "application/problem+") {
+			return nil
+		}
+		fs, ok := parseFields(ct, body)
+		if !ok {
+			return nil
+		}
+		code, ok := fs.int(statusField)
+		if !ok || code == 0 {
+			return nil
+		}
+		msg, ok := fs.string(messageField)
+		if !ok {
+			return nil
+		}
+		return withDetails(gErrors.New(code, msg), fs.details(detailsField))
+	}
+}
+
+// ProblemErrorDecoder 解析render.NewProblem格式(RFC 7807) 优先使用code字段作为业务码
+func ProblemErrorDecoder() ErrorDecoder {
+	return func(resp *http.Response, body []byte) error {
+		ct := mediaType(resp.Header.Get("Content-Type"))
+		if !strings.HasPrefix(ct, "application/problem+") {
+			return nil
+		}
+		fs, ok := parseFields(ct, body)
+		if !ok {
+			return nil
+		}
+		code, ok := fs.int("code")
+		if !ok {
+			code = resp.StatusCode
+		}
+		msg, ok := fs.string("detail")
+		if !ok {
+			msg, _ = fs.string("title")
+		}
+		return withDetails(gErrors.New(code, msg), fs.details("errors"))
+	}
+}
+
+// StatusProtoErrorDecoder 解析protobuf错误响应google.rpc.Status
+// Content-Type带proto=google.rpc.Status参数时直接解析 否则只解析非2xx响应
+func StatusProtoErrorDecoder() ErrorDecoder {
+	return func(resp *http.Response, body []byte) error {
+		mt, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil || (mt != "application/x-protobuf" && mt != "application/protobuf") {
+			return nil
+		}
+		if isSuccess(resp.StatusCode) && params["proto"] != "google.rpc.Status" {
+			return nil
+		}
+		st := &spb.Status{}
+		if err := proto.Unmarshal(body, st); err != nil || (st.Code == 0 && st.Message == "") {
+			return nil
+		}
+		var details []*gErrors.FieldViolation
+		for _, d := range st.Details {
+			br := &errdetails.BadRequest{}
+			if d.UnmarshalTo(br) != nil {
+				continue
+			}
+			for _, fv := range br.FieldViolations {
+				details = append(details, &gErrors.FieldViolation{
+					Field:   fv.Field,
+					Message: fv.Description,
+				})
+			}
+		}
+		return withDetails(gErrors.New(int(st.Code), st.Message), details)
+	}
+}
+
+func withDetails(err *gErrors.Error, details []*gErrors.FieldViolation) error {
+	if len(details) > 0 {
+		return err.WithDetails(details...)
+	}
+	return err
+}
+
+// fieldSet json对象或xml<response>的一级字段
+type fieldSet struct {
+	json map[string]json.RawMessage
+	xml  map[string]string
+}
+
+type xmlFields struct {
+	Fields []struct {
+		XMLName xml.Name
+		Inner   string `xml:",innerxml"`
+	} `xml:",any"`
+}
+
+func parseFields(ct string, body []byte) (*fieldSet, bool) {
+	if len(body) == 0 {
+		return nil, false
+	}
+	switch {
+	case strings.HasSuffix(ct, "json"):
+		m := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(body, &m); err != nil {
+			return nil, false
+		}
+		return &fieldSet{json: m}, true
+	case strings.HasSuffix(ct, "xml"):
+		var x xmlFields
+		if err := xml.Unmarshal(body, &x); err != nil {
+			return nil, false
+		}
+		m := make(map[string]string, len(x.Fields))
+		for _, f := range x.Fields {
+			m[f.XMLName.Local] = f.Inner
+		}
+		return &fieldSet{xml: m}, true
+	}
+	return nil, false
+}
+
+func (fs *fieldSet) int(name string) (int, bool) {
+	if fs.json != nil {
+		raw, has := fs.json[name]
+		if !has {
+			return 0, false
+		}
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return 0, false
+		}
+		i, err := strconv.Atoi(n.String())
+		return i, err == nil
+	}
+	inner, has := fs.xml[name]
+	if !has {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(inner))
+	return i, err == nil
+}
+
+func (fs *fieldSet) string(name string) (string, bool) {
+	var s string
+	if fs.json != nil {
+		raw, has := fs.json[name]
+		if !has {
+			return "", false
+		}
+		return s, json.Unmarshal(raw, &s) == nil
+	}
+	inner, has := fs.xml[name]
+	if !has {
+		return "", false
+	}
+	// innerxml未反转义
+	return s, xml.Unmarshal([]byte("<v>"+inner+"</v>"), &s) == nil
+}
+
+func (fs *fieldSet) details(name string) []*gErrors.FieldViolation {
+	var details []*gErrors.FieldViolation
+	if fs.json != nil {
+		if raw, has := fs.json[name]; has {
+			_ = json.Unmarshal(raw, &details)
+		}
+		return details
+	}
+	if inner, has := fs.xml[name]; has {
+		var x struct {
+			Items []*gErrors.FieldViolation `xml:",any"`
+		}
+		if xml.Unmarshal([]byte("<v>"+inner+"</v>"), &x) == nil {
+			details = x.Items
+		}
+	}
+	return details
+}
@@ -2,11 +2,18 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	gCtx "github.com/curry-mz/sagittarius-golang/context"
+	"github.com/curry-mz/sagittarius-golang/cores/client/breaker"
+	"github.com/curry-mz/sagittarius-golang/cores/client/hedge"
+	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/retry"
+	"github.com/curry-mz/sagittarius-golang/cores/logger"
 	"github.com/curry-mz/sagittarius-golang/cores/registry"
 
 	"github.com/opentracing/opentracing-go"
@@ -46,23 +53,20 @@ func doInterceptors(ctx context.Context, cc *Client, req *http.Request) (*http.R
 }
 
 func invoke(ctx context.Context, c *Client, req *http.Request) (*http.Response, error) {
+	done := func(*http.Response, error) {}
 	if c.resolver != nil {
 		var (
-			node *registry.Service
+			host string
 			err  error
 		)
-		if node, err = c.resolver.balancer.Pick(ctx); err != nil {
-			return nil, errors.New("SERVER_NOT_FOUND")
+		if host, done, err = c.pick(ctx); err != nil {
+			return nil, err
 		}
 		if c.insecure {
 			req.URL.Scheme = "http"
 		} else {
 			req.URL.Scheme = "https"
 		}
-		if _, has := node.Hosts["http"]; !has {
-			return nil, errors.New("no matching address found")
-		}
-		host := node.Hosts["http"]
 		if strings.Contains(host, "://") {
 			ss := strings.Split(host, "://")
 			req.URL.Scheme = ss[0]
@@ -72,12 +76,82 @@ func invoke(ctx context.Context, c *Client, req *http.Request) (*http.Response,
 		req.URL.Host = host
 	}
 	resp, err := c.httpClient.Do(req)
+	done(resp, err)
 	if err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
 
+// _pickAttempts 节点熔断时重新选择的次数
+const _pickAttempts = 3
+
+// pick 选择节点 过滤熔断中和被摘除的节点 对冲请求过滤已选择的节点 半开名额被并发占用时重新选择
+// 返回的done在请求结束后调用 记录熔断/摘除和负载均衡统计
+func (c *Client) pick(ctx context.Context) (string, func(resp *http.Response, err error), error) {
+	// 对冲请求跳过之前已选择的节点
+	tried := hedge.FromContext(ctx)
+	if c.breakers != nil || c.detector != nil || tried != nil {
+		ctx = balancer.NewFilterContext(ctx, func(node *registry.Service) bool {
+			host := balancer.Host(node)
+			if tried != nil && tried.Has(host) {
+				return false
+			}
+			if c.detector != nil && c.detector.Ejected(host) {
+				return false
+			}
+			return c.breakers == nil || c.breakers.Get(host).Available()
+		})
+	}
+	var openErr error
+	for i := 0; i < _pickAttempts; i++ {
+		var (
+			node   *registry.Service
+			lbDone func(failed bool)
+			err    error
+		)
+		if db, ok := c.resolver.balancer.(balancer.DoneBalancer); ok {
+			node, lbDone, err = db.PickDone(ctx)
+		} else {
+			node, err = c.resolver.balancer.Pick(ctx)
+		}
+		if err != nil {
+			return "", nil, errors.New("SERVER_NOT_FOUND")
+		}
+		host, has := node.Hosts["http"]
+		if !has {
+			if lbDone != nil {
+				lbDone(false)
+			}
+			return "", nil, errors.New("no matching address found")
+		}
+		bDone := breaker.Done(func(bool) {})
+		if c.breakers != nil {
+			if bDone, err = c.breakers.Get(host).Allow(); err != nil {
+				if lbDone != nil {
+					lbDone(false)
+				}
+				openErr = err
+				continue
+			}
+		}
+		if tried != nil {
+			tried.Add(host)
+		}
+		return host, func(resp *http.Response, err error) {
+			failed := breaker.HttpFailure(resp, err)
+			bDone(failed)
+			if c.detector != nil {
+				c.detector.Record(host, failed)
+			}
+			if lbDone != nil {
+				lbDone(failed)
+			}
+		}, nil
+	}
+	return "", nil, openErr
+}
+
 ///////////////////////////////////////////
 // 客户端拦截器
 ///////////////////////////////////////////
@@ -108,3 +182,55 @@ func TracingInterceptor(baseCtx context.Context, tracer opentracing.Tracer) Inte
 		return invoker(ctx, c, req)
 	}
 }
+
+// RequestIDInterceptor 透传context中的请求id 调用方已设置header时不覆盖
+func RequestIDInterceptor() Interceptor {
+	return func(ctx context.Context, c *Client, req *http.Request, invoker Invoker) (*http.Response, error) {
+		if rid, ok := gCtx.FromRequestIDContext(ctx); ok && gCtx.GetRequestIDHttpHeader(req.Header) == "" {
+			gCtx.SetRequestIDHttpHeader(req.Header, rid)
+		}
+		return invoker(ctx, c, req)
+	}
+}
+
+// BreakerInterceptor 下游整体熔断 打开时返回*breaker.OpenError 网络错误和5xx计为失败
+func BreakerInterceptor(g *breaker.Group) Interceptor {
+	return func(ctx context.Context, c *Client, req *http.Request, invoker Invoker) (*http.Response, error) {
+		done, err := g.Get("").Allow()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := invoker(ctx, c, req)
+		done(breaker.HttpFailure(resp, err))
+		return resp, err
+	}
+}
+
+// AccessInterceptor 记录下游名称/节点/方法/路径/第几次请求/第几次对冲/状态码/耗时/错误到access日志 需放在重试之内
+func AccessInterceptor(lgr *logger.Logger, name string) Interceptor {
+	return func(ctx context.Context, c *Client, req *http.Request, invoker Invoker) (*http.Response, error) {
+		start := time.Now().UnixMilli()
+		resp, err := invoker(ctx, c, req)
+
+		logData := map[string]interface{}{
+			"Service":   name,
+			"Endpoint":  req.URL.Host,
+			"RequestID": gCtx.RequestID(ctx),
+			"Method":    req.Method,
+			"Path":      req.URL.Path,
+			"Attempt":   retry.Attempt(ctx),
+			"Hedge":     hedge.Index(ctx),
+			"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
+		}
+		if resp != nil {
+			logData["Status"] = resp.StatusCode
+		}
+		if err != nil {
+			logData["Error"] = err.Error()
+		}
+		if bs, e := json.Marshal(logData); e == nil {
+			lgr.Write(ctx, "%s", string(bs))
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+///////////////////////////////////////////
+// prometheus指标
+// name为下游名称 每次请求(包括重试)单独统计 网络错误的code记为error
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http_client",
+		Name:      "requests_total",
+		Help:      "Total number of outbound http requests.",
+	}, []string{"name", "method", "code"})
+
+	_requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http_client",
+		Name:      "request_duration_seconds",
+		Help:      "Histogram of outbound http request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"name", "method", "code"})
+
+	_requestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "http_client",
+		Name:      "requests_in_flight",
+		Help:      "Number of outbound http requests in flight.",
+	}, []string{"name"})
+)
+
+// MetricsInterceptor 请求数/耗时/并发数 指标注册到prometheus默认registry 需放在重试之内
+func MetricsInterceptor(name string) Interceptor {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_requestsTotal, _requestDuration, _requestsInFlight)
+	})
+	return func(ctx context.Context, c *Client, req *http.Request, invoker Invoker) (*http.Response, error) {
+		inFlight := _requestsInFlight.WithLabelValues(name)
+		inFlight.Inc()
+		start := time.Now()
+
+		resp, err := invoker(ctx, c, req)
+
+		inFlight.Dec()
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		_requestsTotal.WithLabelValues(name, req.Method, code).Inc()
+		_requestDuration.WithLabelValues(name, req.Method, code).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
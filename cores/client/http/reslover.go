@@ -2,74 +2,119 @@ package http
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/curry-mz/sagittarius-golang/cores/client/http/balancer"
+	"github.com/curry-mz/sagittarius-golang/cores/client/outlier"
 	"github.com/curry-mz/sagittarius-golang/cores/registry"
 
 	"github.com/pkg/errors"
 )
 
+// _watchRetryInterval 服务发现出错后重新监听的间隔
+const _watchRetryInterval = time.Second
+
 type resolver struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	detector  *outlier.Detector
 	eps       []string
 	watcher   registry.Watcher
 	balancer  balancer.Balancer
 	insecure  bool
 	firstChan chan struct{}
+	closeOnce sync.Once
 }
 
-func newResolver(ctx context.Context, watcher registry.Watcher, balanceBuilder balancer.Builder, eps []string, insecure bool) (*resolver, error) {
+// newResolver 等待首次服务发现完成后返回 ctx结束或Close后停止监听
+func newResolver(ctx context.Context, watcher registry.Watcher, balanceBuilder balancer.Builder, detector *outlier.Detector, eps []string, insecure bool) (*resolver, error) {
 	r := &resolver{
+		detector:  detector,
 		watcher:   watcher,
 		balancer:  balanceBuilder.Build(),
 		eps:       eps,
 		insecure:  insecure,
 		firstChan: make(chan struct{}),
 	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	if r.watcher == nil {
+		r.update(r.ctx, r.fallback())
+		return r, nil
+	}
+	go r.watch()
+	select {
+	case <-r.firstChan:
+	case <-r.ctx.Done():
+	}
+	return r, nil
+}
+
+// watch watcher.Start阻塞到节点变化后返回 返回后立即更新 出错时间隔重试
+func (r *resolver) watch() {
 	isFirst := true
-	go func() {
-		if r.watcher == nil {
-			var services []*registry.Service
-			for _, ep := range r.eps {
-				services = append(services, &registry.Service{
-					Hosts: map[string]string{"http": ep},
-				})
+	for {
+		services, err := r.watcher.Start()
+		if r.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
 			}
-			r.balancer.Update(ctx, services)
-			isFirst = false
-			r.firstChan <- struct{}{}
-		} else {
-			for {
-				services, err := r.watcher.Start()
-				if err != nil {
-					if errors.Is(err, context.Canceled) {
-						return
-					}
-					time.Sleep(time.Second)
-					continue
-				}
-				if len(services) == 0 && len(r.eps) != 0 {
-					for _, ep := range r.eps {
-						services = append(services, &registry.Service{
-							Hosts: map[string]string{"http": ep},
-						})
-					}
-				}
-				if len(services) > 0 {
-					r.balancer.Update(ctx, services)
-				}
-				if isFirst {
-					isFirst = false
-					r.firstChan <- struct{}{}
-				}
-				time.Sleep(time.Second)
+			t := time.NewTimer(_watchRetryInterval)
+			select {
+			case <-r.ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
 			}
+			continue
 		}
-	}()
-	<-r.firstChan
-	return r, nil
+		if len(services) == 0 {
+			services = r.fallback()
+		}
+		if len(services) > 0 {
+			r.update(r.ctx, services)
+		}
+		if isFirst {
+			isFirst = false
+			close(r.firstChan)
+		}
+	}
+}
+
+// fallback 兜底配置的节点
+func (r *resolver) fallback() []*registry.Service {
+	var services []*registry.Service
+	for _, ep := range r.eps {
+		services = append(services, &registry.Service{
+			Hosts: map[string]string{"http": ep},
+		})
+	}
+	return services
 }
 
+// update 更新负载均衡节点 同步异常节点摘除的节点列表
+func (r *resolver) update(ctx context.Context, services []*registry.Service) {
+	r.balancer.Update(ctx, services)
+	if r.detector != nil {
+		hosts := make([]string, 0, len(services))
+		for _, s := range services {
+			hosts = append(hosts, balancer.Host(s))
+		}
+		r.detector.SetHosts(hosts)
+	}
+}
+
+// Close 停止监听 可重复调用
 func (r *resolver) Close() error {
-	return r.watcher.Stop()
+	var err error
+	r.closeOnce.Do(func() {
+		r.cancel()
+		if r.watcher != nil {
+			err = r.watcher.Stop()
+		}
+	})
+	return err
 }
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+///////////////////////////////////////////
+// Do返回的响应
+// body未读取 调用方读取后需要Close 读取方法会自动关闭
+///////////////////////////////////////////
+
+type Response struct {
+	*http.Response
+	req      *Req
+	decoders []ErrorDecoder
+}
+
+// IsSuccess 2xx
+func (r *Response) IsSuccess() bool {
+	return isSuccess(r.StatusCode)
+}
+
+// Stream 流式读取body 已按Content-Encoding解码
+func (r *Response) Stream() io.ReadCloser {
+	return r.Body
+}
+
+func (r *Response) Close() error {
+	return r.Body.Close()
+}
+
+// Bytes 读取全部body
+func (r *Response) Bytes() ([]byte, error) {
+	defer r.Body.Close()
+	return io.ReadAll(r.Body)
+}
+
+func (r *Response) String() (string, error) {
+	bs, err := r.Bytes()
+	return string(bs), err
+}
+
+// Err 非2xx或业务错误时返回*HTTPError 会读取全部body 读取后body仍可再次读取
+func (r *Response) Err() error {
+	bs, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(bs))
+	return checkResponse(r.decoders, r.Response, bs)
+}
+
+// Bind 按响应Content-Type解析 缺失时使用请求的Accept 非2xx或业务错误时返回*HTTPError
+func (r *Response) Bind(v interface{}) error {
+	bs, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	if err = checkResponse(r.decoders, r.Response, bs); err != nil {
+		return err
+	}
+	if len(bs) == 0 {
+		return nil
+	}
+	return bind(r.req, r.Response, bs, v)
+}
+
+// SaveTo 将body写入w 返回写入长度
+func (r *Response) SaveTo(w io.Writer) (int64, error) {
+	defer r.Body.Close()
+	return io.Copy(w, r.Body)
+}
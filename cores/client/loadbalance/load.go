@@ -0,0 +1,116 @@
+package loadbalance
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+///////////////////////////////////////////
+// http和rpc客户端共用的负载均衡算法
+// 节点负载/平滑加权轮询/一致性哈希环 两种客户端的策略参数保持一致
+///////////////////////////////////////////
+
+// _decay 延迟EWMA的衰减时间
+const _decay = float64(10 * time.Second)
+
+// _failPenalty 失败请求按该延迟计算 使失败节点的分数升高
+const _failPenalty = float64(time.Second)
+
+// Load 节点负载 进行中的请求数和延迟EWMA
+type Load struct {
+	inflight int64
+
+	mu    sync.Mutex
+	ewma  float64
+	stamp time.Time
+}
+
+func (l *Load) Inflight() int64 {
+	return atomic.LoadInt64(&l.inflight)
+}
+
+// Latency 延迟EWMA 单位ns 没有请求时为0
+func (l *Load) Latency() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ewma
+}
+
+// Score p2c使用的分数 延迟EWMA*(进行中请求数+1) 没有延迟数据时按进行中请求数比较
+func (l *Load) Score() float64 {
+	return (l.Latency() + 1) * float64(l.Inflight()+1)
+}
+
+// Start 请求开始 返回请求结束时调用的函数 failed为true时按失败计算延迟
+func (l *Load) Start() func(failed bool) {
+	atomic.AddInt64(&l.inflight, 1)
+	start := time.Now()
+	return func(failed bool) {
+		atomic.AddInt64(&l.inflight, -1)
+		now := time.Now()
+		latency := float64(now.Sub(start))
+		if failed {
+			latency = math.Max(latency, _failPenalty)
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.stamp.IsZero() {
+			l.ewma = latency
+		} else {
+			// 按时间衰减 间隔越久旧值权重越低
+			w := math.Exp(-float64(now.Sub(l.stamp)) / _decay)
+			l.ewma = l.ewma*w + latency*(1-w)
+		}
+		l.stamp = now
+	}
+}
+
+// Loads 按节点地址保存负载 节点更新后保留已有节点的统计
+type Loads struct {
+	mu    sync.RWMutex
+	loads map[string]*Load
+}
+
+func (ls *Loads) Get(addr string) *Load {
+	ls.mu.RLock()
+	l, has := ls.loads[addr]
+	ls.mu.RUnlock()
+	if has {
+		return l
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, has = ls.loads[addr]; has {
+		return l
+	}
+	if ls.loads == nil {
+		ls.loads = make(map[string]*Load)
+	}
+	l = &Load{}
+	ls.loads[addr] = l
+	return l
+}
+
+// Retain 只保留addrs中的节点
+func (ls *Loads) Retain(addrs map[string]bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for addr := range ls.loads {
+		if !addrs[addr] {
+			delete(ls.loads, addr)
+		}
+	}
+}
+
+// Pair p2c随机选择两个不同的下标 n需大于1
+func Pair(n int) (int, int) {
+	i := rand.Intn(n)
+	j := rand.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	return i, j
+}
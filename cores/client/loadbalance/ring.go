@@ -0,0 +1,59 @@
+package loadbalance
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas 每个节点的虚拟节点数
+const DefaultReplicas = 160
+
+type point struct {
+	hash  uint32
+	index int
+}
+
+// Ring 一致性哈希环 按节点地址生成虚拟节点
+type Ring struct {
+	points []point
+}
+
+// NewRing addrs为节点地址 选择结果为addrs的下标 replicas<=0时使用默认值
+func NewRing(addrs []string, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	points := make([]point, 0, len(addrs)*replicas)
+	for idx, addr := range addrs {
+		for i := 0; i < replicas; i++ {
+			points = append(points, point{
+				hash:  crc32.ChecksumIEEE([]byte(addr + "#" + strconv.Itoa(i))),
+				index: idx,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].hash < points[j].hash
+	})
+	return &Ring{points: points}
+}
+
+// Get key命中的节点 不可用时顺时针选择下一个可用节点 全部不可用时返回命中的节点 由调用方处理
+// 环为空时返回-1
+func (r *Ring) Get(key string, available func(i int) bool) int {
+	if len(r.points) == 0 {
+		return -1
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	for i := 0; i < len(r.points); i++ {
+		p := r.points[(start+i)%len(r.points)]
+		if available == nil || available(p.index) {
+			return p.index
+		}
+	}
+	return r.points[start%len(r.points)].index
+}
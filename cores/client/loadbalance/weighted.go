@@ -0,0 +1,61 @@
+package loadbalance
+
+import (
+	"strconv"
+)
+
+// DefaultWeight 未配置权重时的默认值
+const DefaultWeight = 100
+
+// WeightKey 服务发现元数据中权重的key
+const WeightKey = "weight"
+
+// ParseWeight 解析元数据中的权重 缺失或非法时使用默认值 0表示不参与加权选择
+func ParseWeight(v string) int {
+	w, err := strconv.Atoi(v)
+	if err != nil || w < 0 {
+		return DefaultWeight
+	}
+	return w
+}
+
+// Smooth 平滑加权轮询(nginx) 按下标选择 并发需由调用方加锁
+type Smooth struct {
+	current []int
+}
+
+// NewSmooth prev不为nil时保留相同节点的当前值 避免节点更新后集中请求 keep为新下标对应的旧下标 -1为新节点
+func NewSmooth(n int, prev *Smooth, keep func(i int) int) *Smooth {
+	s := &Smooth{current: make([]int, n)}
+	if prev == nil || keep == nil {
+		return s
+	}
+	for i := range s.current {
+		if j := keep(i); j >= 0 && j < len(prev.current) {
+			s.current[i] = prev.current[j]
+		}
+	}
+	return s
+}
+
+// Next 选择下一个节点 先在available的节点中选择 没有时忽略available 由调用方处理
+// 权重全部为0时返回-1
+func (s *Smooth) Next(weights []int, available func(i int) bool) int {
+	best, total := -1, 0
+	for round := 0; round < 2 && best < 0; round++ {
+		for i, w := range weights {
+			if w == 0 || (round == 0 && available != nil && !available(i)) {
+				continue
+			}
+			s.current[i] += w
+			total += w
+			if best < 0 || s.current[i] > s.current[best] {
+				best = i
+			}
+		}
+	}
+	if best >= 0 {
+		s.current[best] -= total
+	}
+	return best
+}
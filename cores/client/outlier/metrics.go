@@ -0,0 +1,40 @@
+package outlier
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+///////////////////////////////////////////
+// prometheus指标
+// name为下游名称
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_ejected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "client_outlier",
+		Name:      "ejected",
+		Help:      "Whether the endpoint is ejected, 1 ejected, 0 not.",
+	}, []string{"name", "endpoint"})
+
+	_ejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "client_outlier",
+		Name:      "ejections_total",
+		Help:      "Total number of endpoint ejections.",
+	}, []string{"name", "reason"})
+
+	_skipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "client_outlier",
+		Name:      "ejections_skipped_total",
+		Help:      "Total number of ejections skipped by max ejection percent.",
+	}, []string{"name", "reason"})
+)
+
+func registerMetrics() {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_ejected, _ejections, _skipped)
+	})
+}
@@ -0,0 +1,274 @@
+package outlier
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/curry-mz/sagittarius-golang/cores/logger"
+)
+
+///////////////////////////////////////////
+// http/rpc客户端共用的异常节点摘除
+// 连续失败达到阈值或错误率明显高于其他节点时摘除一段时间 多次摘除时间递增
+// 摘除节点数不超过最大比例 摘除期间节点不参与负载均衡 连接保留
+///////////////////////////////////////////
+
+// 摘除原因
+const (
+	ReasonConsecutive = "consecutive_failures"
+	ReasonErrorRate   = "error_rate"
+)
+
+// Config 摘除阈值 零值字段使用默认值
+type Config struct {
+	// Disabled 不摘除
+	Disabled bool
+	// ConsecutiveFailures 连续失败次数 默认5
+	ConsecutiveFailures int
+	// Interval 错误率统计周期 默认10s
+	Interval time.Duration
+	// BaseEjectionTime 基础摘除时间 第n次摘除为n倍 默认30s
+	BaseEjectionTime time.Duration
+	// MaxEjectionTime 最长摘除时间 默认300s
+	MaxEjectionTime time.Duration
+	// MaxEjectionPercent 最多摘除的节点比例 0-100 默认10 节点数大于1时至少允许摘除1个
+	MaxEjectionPercent int
+	// ErrorRateStdev 成功率低于平均值减去该倍数标准差时摘除 默认1.9
+	ErrorRateStdev float64
+	// MinRequests 周期内请求数达到后才参与错误率计算 默认50
+	MinRequests int
+	// MinHosts 参与错误率计算的节点数下限 默认3
+	MinHosts int
+}
+
+func (c Config) withDefaults() Config {
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.Interval <= 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.BaseEjectionTime <= 0 {
+		c.BaseEjectionTime = 30 * time.Second
+	}
+	if c.MaxEjectionTime <= 0 {
+		c.MaxEjectionTime = 300 * time.Second
+	}
+	if c.MaxEjectionPercent <= 0 {
+		c.MaxEjectionPercent = 10
+	}
+	if c.ErrorRateStdev <= 0 {
+		c.ErrorRateStdev = 1.9
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 50
+	}
+	if c.MinHosts <= 0 {
+		c.MinHosts = 3
+	}
+	return c
+}
+
+type host struct {
+	consecutive  int
+	total        int64
+	failures     int64
+	ejected      bool
+	ejectedUntil time.Time
+	// multiplier 摘除次数 未被摘除的周期递减
+	multiplier int
+}
+
+// Detector 同一下游的节点摘除 节点以地址标识
+type Detector struct {
+	name string
+	lgr  *logger.Logger
+
+	mu         sync.Mutex
+	cfg        Config
+	hosts      map[string]*host
+	analyzedAt time.Time
+}
+
+// NewDetector lgr为nil时不记录日志
+func NewDetector(name string, cfg Config, lgr *logger.Logger) *Detector {
+	registerMetrics()
+	return &Detector{
+		name:       name,
+		lgr:        lgr,
+		cfg:        cfg.withDefaults(),
+		hosts:      make(map[string]*host),
+		analyzedAt: time.Now(),
+	}
+}
+
+func (d *Detector) Name() string {
+	return d.name
+}
+
+// SetHosts 服务发现的全部节点 用于计算摘除比例 移除已下线的节点
+func (d *Detector) SetHosts(addrs []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	exists := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		exists[addr] = true
+		if _, has := d.hosts[addr]; !has {
+			d.hosts[addr] = &host{}
+		}
+	}
+	for addr := range d.hosts {
+		if !exists[addr] {
+			delete(d.hosts, addr)
+			_ejected.DeleteLabelValues(d.name, addr)
+		}
+	}
+}
+
+// Ejected 节点是否被摘除
+func (d *Detector) Ejected(addr string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cfg.Disabled {
+		return false
+	}
+	d.check(time.Now())
+	h, has := d.hosts[addr]
+	return has && h.ejected
+}
+
+// Record 记录请求结果
+func (d *Detector) Record(addr string, failed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cfg.Disabled {
+		return
+	}
+	now := time.Now()
+	d.check(now)
+	h, has := d.hosts[addr]
+	if !has {
+		h = &host{}
+		d.hosts[addr] = h
+	}
+	h.total++
+	if !failed {
+		h.consecutive = 0
+		return
+	}
+	h.failures++
+	h.consecutive++
+	if !h.ejected && h.consecutive >= d.cfg.ConsecutiveFailures {
+		d.eject(addr, h, ReasonConsecutive, now)
+	}
+}
+
+// Update 更新阈值 关闭时恢复全部节点
+func (d *Detector) Update(cfg Config) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg = cfg.withDefaults()
+	if d.cfg.Disabled {
+		for addr, h := range d.hosts {
+			if h.ejected {
+				d.uneject(addr, h)
+			}
+			h.multiplier = 0
+		}
+	}
+}
+
+// check 恢复到期的节点 每个周期计算一次错误率
+func (d *Detector) check(now time.Time) {
+	for addr, h := range d.hosts {
+		if h.ejected && !now.Before(h.ejectedUntil) {
+			d.uneject(addr, h)
+		}
+	}
+	if now.Sub(d.analyzedAt) < d.cfg.Interval {
+		return
+	}
+	d.analyzedAt = now
+	d.analyze(now)
+	for _, h := range d.hosts {
+		if !h.ejected && h.multiplier > 0 {
+			h.multiplier--
+		}
+		h.total, h.failures = 0, 0
+	}
+}
+
+// analyze 成功率低于 平均值-倍数*标准差 的节点摘除
+func (d *Detector) analyze(now time.Time) {
+	rates := make(map[string]float64)
+	var sum float64
+	for addr, h := range d.hosts {
+		if h.ejected || h.total < int64(d.cfg.MinRequests) {
+			continue
+		}
+		rate := float64(h.total-h.failures) / float64(h.total)
+		rates[addr] = rate
+		sum += rate
+	}
+	if len(rates) < d.cfg.MinHosts {
+		return
+	}
+	mean := sum / float64(len(rates))
+	var variance float64
+	for _, rate := range rates {
+		variance += (rate - mean) * (rate - mean)
+	}
+	threshold := mean - d.cfg.ErrorRateStdev*math.Sqrt(variance/float64(len(rates)))
+	for addr, rate := range rates {
+		if rate < threshold {
+			d.eject(addr, d.hosts[addr], ReasonErrorRate, now)
+		}
+	}
+}
+
+func (d *Detector) eject(addr string, h *host, reason string, now time.Time) {
+	if !d.canEject() {
+		_skipped.WithLabelValues(d.name, reason).Inc()
+		return
+	}
+	h.multiplier++
+	td := d.cfg.BaseEjectionTime * time.Duration(h.multiplier)
+	if td > d.cfg.MaxEjectionTime {
+		td = d.cfg.MaxEjectionTime
+	}
+	h.ejected = true
+	h.ejectedUntil = now.Add(td)
+	h.consecutive = 0
+	_ejections.WithLabelValues(d.name, reason).Inc()
+	_ejected.WithLabelValues(d.name, addr).Set(1)
+	if d.lgr != nil {
+		d.lgr.Warn(context.Background(), "outlier eject, name:%s, endpoint:%s, reason:%s, duration:%s",
+			d.name, addr, reason, td)
+	}
+}
+
+func (d *Detector) uneject(addr string, h *host) {
+	h.ejected = false
+	h.consecutive = 0
+	_ejected.WithLabelValues(d.name, addr).Set(0)
+	if d.lgr != nil {
+		d.lgr.Info(context.Background(), "outlier uneject, name:%s, endpoint:%s", d.name, addr)
+	}
+}
+
+// canEject 已摘除节点数未达到最大比例
+func (d *Detector) canEject() bool {
+	var ejected int
+	for _, h := range d.hosts {
+		if h.ejected {
+			ejected++
+		}
+	}
+	max := len(d.hosts) * d.cfg.MaxEjectionPercent / 100
+	if max < 1 && len(d.hosts) > 1 {
+		max = 1
+	}
+	return ejected < max
+}
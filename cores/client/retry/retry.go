@@ -0,0 +1,287 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+///////////////////////////////////////////
+// http/rpc客户端共用的重试策略
+// 指数退避+抖动 可重试状态码 幂等限制 单次超时 重试预算
+///////////////////////////////////////////
+
+type Option func(*Policy)
+
+type Policy struct {
+	attempts          int
+	initialBackoff    time.Duration
+	maxBackoff        time.Duration
+	multiplier        float64
+	jitter            float64
+	perAttemptTimeout time.Duration
+	statuses          map[int]bool
+	codes             map[codes.Code]bool
+	nonIdempotent     bool
+	idempotentMethods map[string]bool
+	budget            *Budget
+}
+
+// Attempts 最大重试次数 不包含首次请求
+func Attempts(n int) Option {
+	return func(p *Policy) {
+		p.attempts = n
+	}
+}
+
+// Backoff 首次重试等待时间和最大等待时间 默认50ms/1s
+func Backoff(initial time.Duration, max time.Duration) Option {
+	return func(p *Policy) {
+		p.initialBackoff = initial
+		p.maxBackoff = max
+	}
+}
+
+// Multiplier 退避倍数 默认2
+func Multiplier(m float64) Option {
+	return func(p *Policy) {
+		p.multiplier = m
+	}
+}
+
+// Jitter 抖动比例 0-1 默认0.2 等待时间在(1±jitter)倍之间随机
+func Jitter(j float64) Option {
+	return func(p *Policy) {
+		p.jitter = j
+	}
+}
+
+// PerAttemptTimeout 单次请求超时 不超过调用方context的deadline
+func PerAttemptTimeout(d time.Duration) Option {
+	return func(p *Policy) {
+		p.perAttemptTimeout = d
+	}
+}
+
+// RetryOnStatus 可重试的http状态码 默认502/503/504
+func RetryOnStatus(statuses ...int) Option {
+	return func(p *Policy) {
+		p.statuses = make(map[int]bool, len(statuses))
+		for _, s := range statuses {
+			p.statuses[s] = true
+		}
+	}
+}
+
+// RetryOnCodes 可重试的grpc状态码 默认Unavailable/DeadlineExceeded
+func RetryOnCodes(cs ...codes.Code) Option {
+	return func(p *Policy) {
+		p.codes = make(map[codes.Code]bool, len(cs))
+		for _, c := range cs {
+			p.codes[c] = true
+		}
+	}
+}
+
+// RetryNonIdempotent 允许非幂等请求重试 默认http只重试GET/HEAD/OPTIONS/TRACE/PUT/DELETE
+func RetryNonIdempotent() Option {
+	return func(p *Policy) {
+		p.nonIdempotent = true
+	}
+}
+
+// IdempotentMethods 声明幂等的grpc方法 例如/pkg.Service/Get grpc默认只在Unavailable时重试
+func IdempotentMethods(methods ...string) Option {
+	return func(p *Policy) {
+		for _, m := range methods {
+			p.idempotentMethods[m] = true
+		}
+	}
+}
+
+// WithBudget 重试预算 同一下游共用
+func WithBudget(b *Budget) Option {
+	return func(p *Policy) {
+		p.budget = b
+	}
+}
+
+func New(opts ...Option) *Policy {
+	p := &Policy{
+		initialBackoff:    50 * time.Millisecond,
+		maxBackoff:        time.Second,
+		multiplier:        2,
+		jitter:            0.2,
+		statuses:          map[int]bool{http.StatusBadGateway: true, http.StatusServiceUnavailable: true, http.StatusGatewayTimeout: true},
+		codes:             map[codes.Code]bool{codes.Unavailable: true, codes.DeadlineExceeded: true},
+		idempotentMethods: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *Policy) MaxAttempts() int {
+	return p.attempts
+}
+
+// Backoff 第n次重试(从1开始)前的等待时间
+func (p *Policy) Backoff(n int) time.Duration {
+	if p.initialBackoff <= 0 || n <= 0 {
+		return 0
+	}
+	d := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(n-1))
+	if p.maxBackoff > 0 && d > float64(p.maxBackoff) {
+		d = float64(p.maxBackoff)
+	}
+	if p.jitter > 0 {
+		d *= 1 + p.jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// Wait 等待第n次重试 context结束时返回错误
+func (p *Policy) Wait(ctx context.Context, n int) error {
+	d := p.Backoff(n)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// AttemptContext 单次请求的context attempt为第几次请求 从1开始 通过Attempt读取
+func (p *Policy) AttemptContext(ctx context.Context, attempt int) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, attemptKey{}, attempt)
+	if p.perAttemptTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.perAttemptTimeout)
+}
+
+type attemptKey struct{}
+
+// Attempt 当前是第几次请求 首次为1 未经过重试策略时为1
+func Attempt(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(int); ok {
+		return n
+	}
+	return 1
+}
+
+// RetryableStatus http状态码是否可重试
+func (p *Policy) RetryableStatus(status int) bool {
+	return p.statuses[status]
+}
+
+// HttpAllowed http方法是否允许重试
+func (p *Policy) HttpAllowed(method string) bool {
+	if p.nonIdempotent {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// RpcRetryable grpc错误码是否可重试
+// Unavailable通常表示请求未到达服务端 其余错误码只对幂等方法重试
+func (p *Policy) RpcRetryable(method string, code codes.Code) bool {
+	if !p.codes[code] {
+		return false
+	}
+	return code == codes.Unavailable || p.nonIdempotent || p.idempotentMethods[method]
+}
+
+// Deposit 每次调用(非重试)存入预算
+func (p *Policy) Deposit() {
+	if p.budget != nil {
+		p.budget.Deposit()
+	}
+}
+
+// Withdraw 重试前从预算中扣除 预算不足时返回false
+func (p *Policy) Withdraw() bool {
+	if p.budget == nil {
+		return true
+	}
+	return p.budget.Withdraw()
+}
+
+///////////////////////////////////////////
+// 重试预算 令牌桶
+// 每次调用存入ratio个令牌 每秒补充minPerSec个令牌 每次重试消耗1个令牌
+// 下游故障时重试量被限制在调用量的ratio倍左右 避免重试风暴
+///////////////////////////////////////////
+
+type Budget struct {
+	mu        sync.Mutex
+	ratio     float64
+	minPerSec float64
+	max       float64
+	tokens    float64
+	last      time.Time
+}
+
+// NewBudget ratio为重试占调用量的比例 例如0.1 minPerSec为低流量时每秒保底的重试次数
+func NewBudget(ratio float64, minPerSec int) *Budget {
+	// 令牌上限为10秒的保底量 至少为10
+	max := float64(minPerSec) * 10
+	if max < 10 {
+		max = 10
+	}
+	return &Budget{
+		ratio:     ratio,
+		minPerSec: float64(minPerSec),
+		max:       max,
+		tokens:    max,
+		last:      time.Now(),
+	}
+}
+
+func (b *Budget) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.minPerSec
+	b.last = now
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+// Deposit 每次调用存入ratio个令牌
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+// Withdraw 消耗1个令牌 不足时返回false
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
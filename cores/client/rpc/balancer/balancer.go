@@ -0,0 +1,264 @@
+package balancer
+
+import (
+	"context"
+	"sort"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/breaker"
+	"github.com/curry-mz/sagittarius-golang/cores/client/hedge"
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
+	"github.com/curry-mz/sagittarius-golang/cores/client/outlier"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/attributes"
+	gBalancer "google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+///////////////////////////////////////////
+// grpc负载均衡 策略与http客户端一致
+// 选择节点时跳过熔断中和被摘除的节点 熔断器组和摘除检测通过地址的BalancerAttributes传入
+// 对冲请求跳过同一请求已选择的节点
+///////////////////////////////////////////
+
+// 注册的balancer名称
+const (
+	Random             = "sagittarius_random"
+	RoundRobin         = "sagittarius_round_robin"
+	WeightedRoundRobin = "sagittarius_weighted_round_robin"
+	LeastRequest       = "sagittarius_least_request"
+	P2C                = "sagittarius_p2c"
+	ConsistentHash     = "sagittarius_consistent_hash"
+)
+
+var _names = map[string]string{
+	"random":               Random,
+	"round_robin":          RoundRobin,
+	"weighted_round_robin": WeightedRoundRobin,
+	"least_request":        LeastRequest,
+	"p2c":                  P2C,
+	"consistent_hash":      ConsistentHash,
+}
+
+// Name 策略名称转换为注册的balancer名称 round_robin同样替换 其余名称原样返回 例如pick_first
+func Name(strategy string) string {
+	if name, has := _names[strategy]; has {
+		return name
+	}
+	return strategy
+}
+
+func init() {
+	register(Random, newRandomPicker)
+	register(RoundRobin, newRRPicker)
+	register(WeightedRoundRobin, newWeightedPicker)
+	register(LeastRequest, newLeastRequestPicker)
+	register(P2C, newP2CPicker)
+	register(ConsistentHash, newHashPicker)
+}
+
+// WeightKey 地址Attributes中权重的key 来自服务发现元数据
+const WeightKey = loadbalance.WeightKey
+
+// DefaultWeight 未配置权重时的默认值
+const DefaultWeight = loadbalance.DefaultWeight
+
+type breakerKey struct{}
+
+// WithBreaker 地址携带熔断器组
+func WithBreaker(addr resolver.Address, g *breaker.Group) resolver.Address {
+	if addr.BalancerAttributes == nil {
+		addr.BalancerAttributes = attributes.New(breakerKey{}, g)
+	} else {
+		addr.BalancerAttributes = addr.BalancerAttributes.WithValue(breakerKey{}, g)
+	}
+	return addr
+}
+
+func getBreaker(addr resolver.Address) *breaker.Group {
+	g, _ := addr.BalancerAttributes.Value(breakerKey{}).(*breaker.Group)
+	return g
+}
+
+type outlierKey struct{}
+
+// WithOutlier 地址携带异常节点摘除检测
+func WithOutlier(addr resolver.Address, d *outlier.Detector) resolver.Address {
+	if addr.BalancerAttributes == nil {
+		addr.BalancerAttributes = attributes.New(outlierKey{}, d)
+	} else {
+		addr.BalancerAttributes = addr.BalancerAttributes.WithValue(outlierKey{}, d)
+	}
+	return addr
+}
+
+func getOutlier(addr resolver.Address) *outlier.Detector {
+	d, _ := addr.BalancerAttributes.Value(outlierKey{}).(*outlier.Detector)
+	return d
+}
+
+type hashKey struct{}
+
+// NewHashKeyContext 一致性哈希使用的key 相同key请求同一节点
+func NewHashKeyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+func FromHashKeyContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKey{}).(string)
+	return key, ok && key != ""
+}
+
+// endpoint 可用的节点 同一连接内跨picker保留统计
+type endpoint struct {
+	sc       gBalancer.SubConn
+	addr     resolver.Address
+	breaker  *breaker.Breaker
+	detector *outlier.Detector
+	weight   int
+	load     loadbalance.Load
+}
+
+func newEndpoint(sc gBalancer.SubConn, addr resolver.Address) *endpoint {
+	ep := &endpoint{sc: sc, addr: addr, weight: DefaultWeight}
+	if g := getBreaker(addr); g != nil {
+		ep.breaker = g.Get(addr.Addr)
+	}
+	ep.detector = getOutlier(addr)
+	if v, ok := addr.Attributes.Value(WeightKey).(string); ok {
+		ep.weight = loadbalance.ParseWeight(v)
+	}
+	return ep
+}
+
+// available tried为对冲请求已选择的节点
+func (ep *endpoint) available(tried *hedge.Tried) bool {
+	if tried != nil && tried.Has(ep.addr.Addr) {
+		return false
+	}
+	if ep.detector != nil && ep.detector.Ejected(ep.addr.Addr) {
+		return false
+	}
+	return ep.breaker == nil || ep.breaker.Available()
+}
+
+// available 过滤熔断中/被摘除/对冲已选择的节点 全部不可用时返回原节点 由pick处理
+func available(eps []*endpoint, tried *hedge.Tried) []*endpoint {
+	res := make([]*endpoint, 0, len(eps))
+	for _, ep := range eps {
+		if ep.available(tried) {
+			res = append(res, ep)
+		}
+	}
+	if len(res) == 0 {
+		return eps
+	}
+	return res
+}
+
+// pick 优先选择first 熔断时按顺序选择后续节点 全部熔断时返回UNAVAILABLE
+func pick(eps []*endpoint, first int, tried *hedge.Tried) (gBalancer.PickResult, error) {
+	var openErr error
+	for i := 0; i < len(eps); i++ {
+		ep := eps[(first+i)%len(eps)]
+		bDone := breaker.Done(func(bool) {})
+		if ep.breaker != nil {
+			var err error
+			if bDone, err = ep.breaker.Allow(); err != nil {
+				openErr = err
+				continue
+			}
+		}
+		if tried != nil {
+			tried.Add(ep.addr.Addr)
+		}
+		lDone := ep.load.Start()
+		return gBalancer.PickResult{
+			SubConn: ep.sc,
+			Done: func(info gBalancer.DoneInfo) {
+				failed := breaker.RpcFailure(info.Err)
+				bDone(failed)
+				lDone(failed)
+				if ep.detector != nil {
+					ep.detector.Record(ep.addr.Addr, failed)
+				}
+			},
+		}, nil
+	}
+	// 没有节点时等待连接就绪
+	if openErr == nil {
+		return gBalancer.PickResult{}, gBalancer.ErrNoSubConnAvailable
+	}
+	// 返回status错误 非wait for ready的请求同样立即失败
+	var oe *breaker.OpenError
+	if errors.As(openErr, &oe) {
+		return gBalancer.PickResult{}, oe.GRPCStatus().Err()
+	}
+	return gBalancer.PickResult{}, status.Error(codes.Unavailable, openErr.Error())
+}
+
+func indexOf(eps []*endpoint, ep *endpoint) int {
+	for i := range eps {
+		if eps[i] == ep {
+			return i
+		}
+	}
+	return 0
+}
+
+///////////////////////////////////////////
+// 每个连接独立的balancer 节点统计在picker重建后保留
+///////////////////////////////////////////
+
+// newPicker prev为上一次构建的picker 用于保留策略状态 首次为nil
+type newPicker func(eps []*endpoint, prev gBalancer.Picker) gBalancer.Picker
+
+func register(name string, np newPicker) {
+	gBalancer.Register(&builder{name: name, newPicker: np})
+}
+
+type builder struct {
+	name      string
+	newPicker newPicker
+}
+
+func (b *builder) Name() string {
+	return b.name
+}
+
+func (b *builder) Build(cc gBalancer.ClientConn, opts gBalancer.BuildOptions) gBalancer.Balancer {
+	t := &tracker{newPicker: b.newPicker, eps: make(map[gBalancer.SubConn]*endpoint)}
+	return base.NewBalancerBuilder(b.name, t, base.Config{HealthCheck: true}).Build(cc, opts)
+}
+
+type tracker struct {
+	newPicker newPicker
+	eps       map[gBalancer.SubConn]*endpoint
+	picker    gBalancer.Picker
+}
+
+func (t *tracker) Build(info base.PickerBuildInfo) gBalancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(gBalancer.ErrNoSubConnAvailable)
+	}
+	eps := make(map[gBalancer.SubConn]*endpoint, len(info.ReadySCs))
+	list := make([]*endpoint, 0, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		ep, has := t.eps[sc]
+		if !has {
+			ep = newEndpoint(sc, sci.Address)
+		}
+		eps[sc] = ep
+		list = append(list, ep)
+	}
+	t.eps = eps
+	// 固定顺序 轮询和哈希在重建后保持稳定
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].addr.Addr < list[j].addr.Addr
+	})
+	t.picker = t.newPicker(list, t.picker)
+	return t.picker
+}
@@ -0,0 +1,181 @@
+package balancer
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/curry-mz/sagittarius-golang/cores/client/hedge"
+	"github.com/curry-mz/sagittarius-golang/cores/client/loadbalance"
+
+	gBalancer "google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+///////////////////////////////////////////
+// 各策略的picker 先在可用节点中按策略选择 再由pick处理熔断
+///////////////////////////////////////////
+
+// randomPicker 随机
+type randomPicker struct {
+	eps []*endpoint
+}
+
+func newRandomPicker(eps []*endpoint, _ gBalancer.Picker) gBalancer.Picker {
+	return &randomPicker{eps: eps}
+}
+
+func (p *randomPicker) Pick(info gBalancer.PickInfo) (gBalancer.PickResult, error) {
+	tried := hedge.FromContext(info.Ctx)
+	eps := available(p.eps, tried)
+	return pick(p.eps, indexOf(p.eps, eps[rand.Intn(len(eps))]), tried)
+}
+
+// rrPicker 轮询
+type rrPicker struct {
+	eps  []*endpoint
+	next uint32
+}
+
+func newRRPicker(eps []*endpoint, _ gBalancer.Picker) gBalancer.Picker {
+	// 随机起点 避免所有客户端同时请求同一节点
+	return &rrPicker{eps: eps, next: uint32(rand.Intn(len(eps)))}
+}
+
+func (p *rrPicker) Pick(info gBalancer.PickInfo) (gBalancer.PickResult, error) {
+	tried := hedge.FromContext(info.Ctx)
+	next := atomic.AddUint32(&p.next, 1)
+	eps := available(p.eps, tried)
+	return pick(p.eps, indexOf(p.eps, eps[next%uint32(len(eps))]), tried)
+}
+
+// weightedPicker 平滑加权轮询 权重来自地址Attributes 权重为0的节点不参与选择
+// picker重建时按地址保留当前值 避免重连后轮询从头开始
+type weightedPicker struct {
+	mu      sync.Mutex
+	eps     []*endpoint
+	weights []int
+	smooth  *loadbalance.Smooth
+}
+
+func newWeightedPicker(eps []*endpoint, prev gBalancer.Picker) gBalancer.Picker {
+	weights := make([]int, 0, len(eps))
+	for _, ep := range eps {
+		weights = append(weights, ep.weight)
+	}
+	p := &weightedPicker{eps: eps, weights: weights}
+	old, ok := prev.(*weightedPicker)
+	if !ok {
+		p.smooth = loadbalance.NewSmooth(len(eps), nil, nil)
+		return p
+	}
+	// 旧picker可能仍在使用
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	idx := make(map[string]int, len(old.eps))
+	for i, ep := range old.eps {
+		idx[ep.addr.Addr] = i
+	}
+	p.smooth = loadbalance.NewSmooth(len(eps), old.smooth, func(i int) int {
+		if j, has := idx[eps[i].addr.Addr]; has {
+			return j
+		}
+		return -1
+	})
+	return p
+}
+
+func (p *weightedPicker) Pick(info gBalancer.PickInfo) (gBalancer.PickResult, error) {
+	tried := hedge.FromContext(info.Ctx)
+	p.mu.Lock()
+	// 全部不可用时忽略熔断 由pick返回熔断错误
+	best := p.smooth.Next(p.weights, func(i int) bool {
+		return p.eps[i].available(tried)
+	})
+	p.mu.Unlock()
+	if best < 0 {
+		return gBalancer.PickResult{}, status.Error(codes.Unavailable, "no endpoint with positive weight")
+	}
+	return pick(p.eps, best, tried)
+}
+
+// leastRequestPicker 最少进行中请求数 相同时随机
+type leastRequestPicker struct {
+	eps []*endpoint
+}
+
+func newLeastRequestPicker(eps []*endpoint, _ gBalancer.Picker) gBalancer.Picker {
+	return &leastRequestPicker{eps: eps}
+}
+
+func (p *leastRequestPicker) Pick(info gBalancer.PickInfo) (gBalancer.PickResult, error) {
+	tried := hedge.FromContext(info.Ctx)
+	var (
+		best *endpoint
+		ties int
+	)
+	for _, ep := range available(p.eps, tried) {
+		switch {
+		case best == nil || ep.load.Inflight() < best.load.Inflight():
+			best, ties = ep, 1
+		case ep.load.Inflight() == best.load.Inflight():
+			ties++
+			if rand.Intn(ties) == 0 {
+				best = ep
+			}
+		}
+	}
+	return pick(p.eps, indexOf(p.eps, best), tried)
+}
+
+// p2cPicker 随机选两个节点 取延迟EWMA*(进行中请求数+1)较小的
+type p2cPicker struct {
+	eps []*endpoint
+}
+
+func newP2CPicker(eps []*endpoint, _ gBalancer.Picker) gBalancer.Picker {
+	return &p2cPicker{eps: eps}
+}
+
+func (p *p2cPicker) Pick(info gBalancer.PickInfo) (gBalancer.PickResult, error) {
+	tried := hedge.FromContext(info.Ctx)
+	eps := available(p.eps, tried)
+	best := eps[0]
+	if len(eps) > 1 {
+		i, j := loadbalance.Pair(len(eps))
+		best = eps[i]
+		if eps[j].load.Score() < best.load.Score() {
+			best = eps[j]
+		}
+	}
+	return pick(p.eps, indexOf(p.eps, best), tried)
+}
+
+// hashPicker 一致性哈希 key通过NewHashKeyContext设置 未设置时随机
+// 命中的节点熔断时顺时针选择下一个节点
+type hashPicker struct {
+	eps  []*endpoint
+	ring *loadbalance.Ring
+}
+
+func newHashPicker(eps []*endpoint, _ gBalancer.Picker) gBalancer.Picker {
+	addrs := make([]string, 0, len(eps))
+	for _, ep := range eps {
+		addrs = append(addrs, ep.addr.Addr)
+	}
+	return &hashPicker{eps: eps, ring: loadbalance.NewRing(addrs, loadbalance.DefaultReplicas)}
+}
+
+func (p *hashPicker) Pick(info gBalancer.PickInfo) (gBalancer.PickResult, error) {
+	tried := hedge.FromContext(info.Ctx)
+	key, ok := FromHashKeyContext(info.Ctx)
+	if !ok {
+		eps := available(p.eps, tried)
+		return pick(p.eps, indexOf(p.eps, eps[rand.Intn(len(eps))]), tried)
+	}
+	best := p.ring.Get(key, func(i int) bool {
+		return p.eps[i].available(tried)
+	})
+	return pick(p.eps, best, tried)
+}
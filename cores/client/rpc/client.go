@@ -5,6 +5,9 @@ import (
 	"crypto/tls"
 	"fmt"
 
+	"github.com/curry-mz/sagittarius-golang/cores/client/breaker"
+	"github.com/curry-mz/sagittarius-golang/cores/client/outlier"
+	"github.com/curry-mz/sagittarius-golang/cores/client/rpc/balancer"
 	"github.com/curry-mz/sagittarius-golang/cores/client/rpc/resolver/direct"
 	"github.com/curry-mz/sagittarius-golang/cores/client/rpc/resolver/discovery"
 	"github.com/curry-mz/sagittarius-golang/cores/registry"
@@ -46,6 +49,13 @@ func WithUnaryInterceptor(in ...grpc.UnaryClientInterceptor) ClientOption {
 	}
 }
 
+// WithStreamInterceptor 流拦截器
+func WithStreamInterceptor(in ...grpc.StreamClientInterceptor) ClientOption {
+	return func(o *clientOptions) {
+		o.streamInts = in
+	}
+}
+
 // WithOptions grpc option
 func WithOptions(opts ...grpc.DialOption) ClientOption {
 	return func(o *clientOptions) {
@@ -53,20 +63,38 @@ func WithOptions(opts ...grpc.DialOption) ClientOption {
 	}
 }
 
-// WithBalancerName 负载均衡策略
+// WithBalancerName 负载均衡策略 random/round_robin/weighted_round_robin/least_request/p2c/consistent_hash
+// 默认round_robin 其余名称需已注册到grpc 例如pick_first
 func WithBalancerName(balancerName string) ClientOption {
 	return func(o *clientOptions) {
 		o.balancerName = balancerName
 	}
 }
 
+// WithBreaker 节点熔断 选择节点时跳过熔断中的节点 下游整体熔断使用BreakerClientUnaryInterceptor
+func WithBreaker(g *breaker.Group) ClientOption {
+	return func(o *clientOptions) {
+		o.breakers = g
+	}
+}
+
+// WithOutlierDetector 异常节点摘除 连续失败或错误率异常的节点一段时间内不参与负载均衡
+func WithOutlierDetector(d *outlier.Detector) ClientOption {
+	return func(o *clientOptions) {
+		o.detector = d
+	}
+}
+
 type clientOptions struct {
 	eps          []string
 	watcher      registry.Watcher
 	tlsCfg       *tls.Config
 	ints         []grpc.UnaryClientInterceptor
+	streamInts   []grpc.StreamClientInterceptor
 	grpcOpts     []grpc.DialOption
 	balancerName string
+	breakers     *breaker.Group
+	detector     *outlier.Detector
 }
 
 func DialContext(ctx context.Context, opts ...ClientOption) (*grpc.ClientConn, error) {
@@ -80,12 +108,15 @@ func dial(ctx context.Context, opts ...ClientOption) (*grpc.ClientConn, error) {
 	for _, o := range opts {
 		o(&options)
 	}
+	// 内置策略替换为跳过熔断节点的实现
+	options.balancerName = balancer.Name(options.balancerName)
 	if len(options.eps) == 0 && options.watcher == nil {
 		return nil, fmt.Errorf("default endpoints is nil and service discovery is nil")
 	}
 	grpcOpts := []grpc.DialOption{
 		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{"%s":{}}]}`, options.balancerName)),
 		grpc.WithChainUnaryInterceptor(options.ints...),
+		grpc.WithChainStreamInterceptor(options.streamInts...),
 	}
 	if options.tlsCfg != nil {
 		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(credentials.NewTLS(options.tlsCfg)))
@@ -101,9 +132,48 @@ func dial(ctx context.Context, opts ...ClientOption) (*grpc.ClientConn, error) {
 	} else {
 		builder = direct.NewBuilder(direct.WithEps(options.eps...))
 	}
+	if options.breakers != nil || options.detector != nil {
+		builder = &attrBuilder{Builder: builder, breakers: options.breakers, detector: options.detector}
+	}
 	grpcOpts = append(grpcOpts, grpc.WithResolvers(builder))
 	if len(options.grpcOpts) > 0 {
 		grpcOpts = append(grpcOpts, options.grpcOpts...)
 	}
 	return grpc.DialContext(ctx, fmt.Sprintf("%s:///", builder.Scheme()), grpcOpts...)
 }
+
+// attrBuilder resolver返回的地址携带熔断器组和摘除检测 供balancer使用
+type attrBuilder struct {
+	resolver.Builder
+	breakers *breaker.Group
+	detector *outlier.Detector
+}
+
+func (b *attrBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	return b.Builder.Build(target, &attrClientConn{ClientConn: cc, b: b}, opts)
+}
+
+type attrClientConn struct {
+	resolver.ClientConn
+	b *attrBuilder
+}
+
+func (cc *attrClientConn) UpdateState(s resolver.State) error {
+	addrs := make([]resolver.Address, 0, len(s.Addresses))
+	hosts := make([]string, 0, len(s.Addresses))
+	for _, addr := range s.Addresses {
+		if cc.b.breakers != nil {
+			addr = balancer.WithBreaker(addr, cc.b.breakers)
+		}
+		if cc.b.detector != nil {
+			addr = balancer.WithOutlier(addr, cc.b.detector)
+		}
+		addrs = append(addrs, addr)
+		hosts = append(hosts, addr.Addr)
+	}
+	if cc.b.detector != nil {
+		cc.b.detector.SetHosts(hosts)
+	}
+	s.Addresses = addrs
+	return cc.ClientConn.UpdateState(s)
+}
@@ -2,17 +2,26 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	gCtx "github.com/curry-mz/sagittarius-golang/context"
+	"github.com/curry-mz/sagittarius-golang/cores/client/breaker"
+	"github.com/curry-mz/sagittarius-golang/cores/client/hedge"
+	"github.com/curry-mz/sagittarius-golang/cores/client/retry"
+	"github.com/curry-mz/sagittarius-golang/cores/logger"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 ///////////////////////////////////////////
@@ -21,38 +30,89 @@ import (
 
 func TracingClientUnaryInterceptor(baseCtx context.Context, tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		//一个RPC调用的服务端的span，和RPC服务客户端的span构成ChildOf关系
-		var parentCtx opentracing.SpanContext
-		parentSpan := opentracing.SpanFromContext(ctx)
-		if parentSpan != nil {
-			parentCtx = parentSpan.Context()
-		}
-		span := tracer.StartSpan(
-			method,
-			opentracing.ChildOf(parentCtx),
-			opentracing.Tag{Key: string(ext.Component), Value: "gRPC Client"},
-			ext.SpanKindRPCClient,
-		)
+		ctx, span := startClientSpan(ctx, baseCtx, tracer, method)
 		defer span.Finish()
+		return invoker(ctx, method, request, reply, cc, opts...)
+	}
+}
 
-		rpcMD, ok := metadata.FromOutgoingContext(ctx)
-		if !ok {
-			rpcMD = metadata.New(nil)
-		} else {
-			rpcMD = rpcMD.Copy()
-		}
-		md := gCtx.Metadata{MD: rpcMD}
-		td, ok := gCtx.FromServerContext(baseCtx)
-		if ok {
-			gCtx.SetUberMeta(md, fmt.Sprintf("%s.%s.%s", td.Namespace, td.Product, td.ServiceName))
-		}
-		if err := tracer.Inject(span.Context(), opentracing.TextMap, md); err == nil {
-			ctx = metadata.NewOutgoingContext(ctx, md.MD)
+// TracingClientStreamInterceptor span在流结束时完成
+func TracingClientStreamInterceptor(baseCtx context.Context, tracer opentracing.Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := startClientSpan(ctx, baseCtx, tracer, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			span.Finish()
+			return nil, err
 		}
-		return invoker(ctx, method, request, reply, cc, opts...)
+		return newClientStream(cs, desc, span.Finish), nil
+	}
+}
+
+func startClientSpan(ctx context.Context, baseCtx context.Context, tracer opentracing.Tracer, method string) (context.Context, opentracing.Span) {
+	//一个RPC调用的服务端的span，和RPC服务客户端的span构成ChildOf关系
+	var parentCtx opentracing.SpanContext
+	parentSpan := opentracing.SpanFromContext(ctx)
+	if parentSpan != nil {
+		parentCtx = parentSpan.Context()
+	}
+	span := tracer.StartSpan(
+		method,
+		opentracing.ChildOf(parentCtx),
+		opentracing.Tag{Key: string(ext.Component), Value: "gRPC Client"},
+		ext.SpanKindRPCClient,
+	)
+
+	rpcMD, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		rpcMD = metadata.New(nil)
+	} else {
+		rpcMD = rpcMD.Copy()
+	}
+	md := gCtx.Metadata{MD: rpcMD}
+	td, ok := gCtx.FromServerContext(baseCtx)
+	if ok {
+		gCtx.SetUberMeta(md, fmt.Sprintf("%s.%s.%s", td.Namespace, td.Product, td.ServiceName))
+	}
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, md); err == nil {
+		ctx = metadata.NewOutgoingContext(ctx, md.MD)
+	}
+	return ctx, span
+}
+
+// RequestIDClientUnaryInterceptor 透传context中的请求id 调用方已设置metadata时不覆盖
+func RequestIDClientUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(requestIDContext(ctx), method, request, reply, cc, opts...)
+	}
+}
+
+// RequestIDClientStreamInterceptor 同RequestIDClientUnaryInterceptor
+func RequestIDClientStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(requestIDContext(ctx), desc, cc, method, opts...)
 	}
 }
 
+func requestIDContext(ctx context.Context) context.Context {
+	rid, ok := gCtx.FromRequestIDContext(ctx)
+	if !ok {
+		return ctx
+	}
+	rpcMD, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		rpcMD = metadata.New(nil)
+	} else {
+		rpcMD = rpcMD.Copy()
+	}
+	md := gCtx.Metadata{MD: rpcMD}
+	if gCtx.GetRequestIDMeta(md) == "" {
+		gCtx.SetRequestIDMeta(md, rid)
+		ctx = metadata.NewOutgoingContext(ctx, md.MD)
+	}
+	return ctx
+}
+
 func TimeoutClientUnaryInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		if timeout > 0 {
@@ -64,19 +124,208 @@ func TimeoutClientUnaryInterceptor(timeout time.Duration) grpc.UnaryClientInterc
 	}
 }
 
+// TimeoutClientStreamInterceptor 整个流的超时 调用方已设置deadline时不生效
+// 长连接的流需由调用方设置足够长的deadline
+func TimeoutClientStreamInterceptor(timeout time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return newClientStream(cs, desc, cancel), nil
+	}
+}
+
+// RetryClientUnaryInterceptor 立即重试 不区分幂等 保持原有行为 建议使用RetryPolicyClientUnaryInterceptor
 func RetryClientUnaryInterceptor(maxAttempts int) grpc.UnaryClientInterceptor {
+	return RetryPolicyClientUnaryInterceptor(retry.New(
+		retry.Attempts(maxAttempts),
+		retry.Backoff(0, 0),
+		retry.RetryNonIdempotent(),
+	))
+}
+
+// RetryPolicyClientUnaryInterceptor 按重试策略重试 需放在超时拦截器之后 单次超时由策略控制
+func RetryPolicyClientUnaryInterceptor(p *retry.Policy) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		var err error
-		for att := 0; att <= maxAttempts; att++ {
-			err = invoker(ctx, method, request, reply, cc, opts...)
-			if err != nil {
-				if status.Convert(err).Code() == codes.Unavailable ||
-					status.Convert(err).Code() == codes.DeadlineExceeded {
-					continue
-				}
+		p.Deposit()
+		for att := 0; ; att++ {
+			actx, cancel := p.AttemptContext(ctx, att+1)
+			err := invoker(actx, method, request, reply, cc, opts...)
+			cancel()
+			if err == nil || att >= p.MaxAttempts() {
+				return err
+			}
+			// 熔断快速失败不重试
+			if errors.Is(breaker.Convert(err), breaker.ErrOpen) {
+				return err
+			}
+			// 上游deadline到期或取消后不再重试
+			if ctx.Err() != nil {
+				return err
+			}
+			if !p.RpcRetryable(method, status.Code(err)) || !p.Withdraw() {
+				return err
 			}
-			break
+			if p.Wait(ctx, att+1) != nil {
+				return err
+			}
+		}
+	}
+}
+
+// BreakerClientUnaryInterceptor 下游整体熔断 打开时返回*breaker.OpenError
+// 节点全部熔断时同样还原为*breaker.OpenError 需放在重试拦截器之后
+func BreakerClientUnaryInterceptor(g *breaker.Group) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, err := g.Get("").Allow()
+		if err != nil {
+			return err
+		}
+		err = invoker(ctx, method, request, reply, cc, opts...)
+		done(breaker.RpcFailure(err))
+		return breaker.Convert(err)
+	}
+}
+
+// AccessClientUnaryInterceptor 记录下游名称/节点/方法/第几次请求/第几次对冲/状态码/耗时/错误到access日志 需放在重试拦截器之后
+func AccessClientUnaryInterceptor(lgr *logger.Logger, name string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now().UnixMilli()
+		// 获取选中的节点
+		var p peer.Peer
+		err := invoker(ctx, method, request, reply, cc, append(opts, grpc.Peer(&p))...)
+
+		logData := map[string]interface{}{
+			"Service":   name,
+			"RequestID": gCtx.RequestID(ctx),
+			"Method":    method,
+			"Attempt":   retry.Attempt(ctx),
+			"Hedge":     hedge.Index(ctx),
+			"Status":    status.Code(err).String(),
+			"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
+		}
+		if p.Addr != nil {
+			logData["Endpoint"] = p.Addr.String()
+		}
+		if err != nil {
+			logData["Error"] = err.Error()
+		}
+		if bs, e := json.Marshal(logData); e == nil {
+			lgr.Write(ctx, "%s", string(bs))
 		}
 		return err
 	}
 }
+
+// HedgeClientUnaryInterceptor 对冲请求 只对声明幂等的方法生效 需放在重试拦截器之后
+// 每次请求使用独立的响应 先成功的结果写入reply 其余请求取消
+func HedgeClientUnaryInterceptor(h *hedge.Policy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		msg, ok := reply.(proto.Message)
+		if !ok || !h.RpcAllowed(method) {
+			return invoker(ctx, method, request, reply, cc, opts...)
+		}
+		h.Deposit()
+		type result struct {
+			reply proto.Message
+			err   error
+		}
+		tried := hedge.NewTried()
+		results := make(chan result, h.MaxAttempts())
+		cancels := make([]context.CancelFunc, 0, h.MaxAttempts())
+		defer func() {
+			for _, cancel := range cancels {
+				cancel()
+			}
+		}()
+		launch := func() {
+			actx, cancel := context.WithCancel(hedge.NewContext(ctx, len(cancels), tried))
+			cancels = append(cancels, cancel)
+			r := proto.Clone(msg)
+			proto.Reset(r)
+			go func() {
+				start := time.Now()
+				err := invoker(actx, method, request, r, cc, opts...)
+				if err == nil {
+					h.Observe(time.Since(start))
+				}
+				results <- result{reply: r, err: err}
+			}()
+		}
+		launch()
+		pending := 1
+		timer := time.NewTimer(h.Delay())
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				if len(cancels) < h.MaxAttempts() && ctx.Err() == nil && h.Withdraw() {
+					launch()
+					pending++
+					timer.Reset(h.Delay())
+				}
+			case res := <-results:
+				pending--
+				if res.err == nil {
+					proto.Reset(msg)
+					proto.Merge(msg, res.reply)
+					return nil
+				}
+				if pending == 0 {
+					return res.err
+				}
+			}
+		}
+	}
+}
+
+///////////////////////////////////////////
+// 流包装 流结束时执行回调
+// 结束指RecvMsg/Header返回错误(包括io.EOF) 或非服务端流的RecvMsg返回
+///////////////////////////////////////////
+
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	onFinish      func()
+}
+
+func newClientStream(cs grpc.ClientStream, desc *grpc.StreamDesc, onFinish func()) grpc.ClientStream {
+	return &clientStream{ClientStream: cs, serverStreams: desc.ServerStreams, onFinish: onFinish}
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.finish()
+	}
+	return md, err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	// io.EOF时状态需通过RecvMsg获取 流未结束
+	if err != nil && err != io.EOF {
+		s.finish()
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.finish()
+	}
+	return err
+}
+
+func (s *clientStream) finish() {
+	s.once.Do(s.onFinish)
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+///////////////////////////////////////////
+// prometheus指标
+// name为下游名称 每次请求(包括重试)单独统计
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rpc_client",
+		Name:      "requests_total",
+		Help:      "Total number of outbound rpc requests.",
+	}, []string{"name", "method", "code"})
+
+	_requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rpc_client",
+		Name:      "request_duration_seconds",
+		Help:      "Histogram of outbound rpc request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"name", "method", "code"})
+
+	_requestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rpc_client",
+		Name:      "requests_in_flight",
+		Help:      "Number of outbound rpc requests in flight.",
+	}, []string{"name"})
+)
+
+// MetricsClientUnaryInterceptor 请求数/耗时/并发数 指标注册到prometheus默认registry 需放在重试拦截器之后
+func MetricsClientUnaryInterceptor(name string) grpc.UnaryClientInterceptor {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_requestsTotal, _requestDuration, _requestsInFlight)
+	})
+	return func(ctx context.Context, method string, request, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		inFlight := _requestsInFlight.WithLabelValues(name)
+		inFlight.Inc()
+		start := time.Now()
+
+		err := invoker(ctx, method, request, reply, cc, opts...)
+
+		inFlight.Dec()
+		code := status.Code(err).String()
+		_requestsTotal.WithLabelValues(name, method, code).Inc()
+		_requestDuration.WithLabelValues(name, method, code).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
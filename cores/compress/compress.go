@@ -0,0 +1,205 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/pkg/errors"
+)
+
+///////////////////////////////////////////
+// http内容编码 server和client共用
+///////////////////////////////////////////
+
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+	Brotli  = "br"
+)
+
+// DefaultLevel 各编码使用自身的默认压缩级别
+const DefaultLevel = -1
+
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// Supported 是否支持该编码 identity视为支持
+func Supported(encoding string) bool {
+	switch normalize(encoding) {
+	case Gzip, Deflate, Brotli, "identity", "":
+		return true
+	}
+	return false
+}
+
+func normalize(encoding string) string {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "x-gzip" {
+		return Gzip
+	}
+	return encoding
+}
+
+// NewReader 按Content-Encoding解码 identity或空时原样返回
+func NewReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch normalize(encoding) {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Deflate:
+		return flate.NewReader(r), nil
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	}
+	return nil, errors.Wrap(ErrUnsupportedEncoding, encoding)
+}
+
+type writer interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var (
+	_poolMu sync.Mutex
+	_pools  = map[string]*sync.Pool{}
+)
+
+func pool(encoding string, level int) *sync.Pool {
+	key := encoding + ":" + strconv.Itoa(level)
+	_poolMu.Lock()
+	defer _poolMu.Unlock()
+	if p, ok := _pools[key]; ok {
+		return p
+	}
+	p := &sync.Pool{}
+	_pools[key] = p
+	return p
+}
+
+// Writer 压缩写入 Close后归还对象池
+type Writer struct {
+	writer
+	pool *sync.Pool
+}
+
+func (w *Writer) Close() error {
+	err := w.writer.Close()
+	if w.pool != nil {
+		w.pool.Put(w.writer)
+		w.pool = nil
+	}
+	return err
+}
+
+// NewWriter 创建压缩writer level为DefaultLevel时使用编码默认级别
+func NewWriter(encoding string, w io.Writer, level int) (*Writer, error) {
+	encoding = normalize(encoding)
+	p := pool(encoding, level)
+	if v := p.Get(); v != nil {
+		ew := v.(writer)
+		ew.Reset(w)
+		return &Writer{writer: ew, pool: p}, nil
+	}
+	var (
+		ew  writer
+		err error
+	)
+	switch encoding {
+	case Gzip:
+		if level == DefaultLevel {
+			level = gzip.DefaultCompression
+		}
+		ew, err = gzip.NewWriterLevel(w, level)
+	case Deflate:
+		if level == DefaultLevel {
+			level = flate.DefaultCompression
+		}
+		ew, err = flate.NewWriter(w, level)
+	case Brotli:
+		if level == DefaultLevel {
+			level = brotli.DefaultCompression
+		}
+		ew = brotli.NewWriterLevel(w, level)
+	default:
+		err = errors.Wrap(ErrUnsupportedEncoding, encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{writer: ew, pool: p}, nil
+}
+
+// Negotiate 按Accept-Encoding的q值选择编码 q值相同时按supported顺序 无可用编码时返回空
+func Negotiate(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" || len(supported) == 0 {
+		return ""
+	}
+	type candidate struct {
+		encoding string
+		q        float64
+		priority int
+	}
+	var (
+		cs       []candidate
+		wildcard = -1.0
+		explicit = map[string]bool{}
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseAccept(part)
+		if name == "" {
+			continue
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		explicit[name] = true
+		for i, s := range supported {
+			if normalize(s) == name && q > 0 {
+				cs = append(cs, candidate{encoding: normalize(s), q: q, priority: i})
+			}
+		}
+	}
+	// *匹配未显式声明的编码
+	if wildcard > 0 {
+		for i, s := range supported {
+			if !explicit[normalize(s)] {
+				cs = append(cs, candidate{encoding: normalize(s), q: wildcard, priority: i})
+			}
+		}
+	}
+	if len(cs) == 0 {
+		return ""
+	}
+	sort.SliceStable(cs, func(i, j int) bool {
+		if cs[i].q != cs[j].q {
+			return cs[i].q > cs[j].q
+		}
+		return cs[i].priority < cs[j].priority
+	})
+	return cs[0].encoding
+}
+
+func parseAccept(part string) (string, float64) {
+	ss := strings.Split(part, ";")
+	name := normalize(ss[0])
+	q := 1.0
+	for _, p := range ss[1:] {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "q=") {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(p, "q="), 64)
+			if err != nil {
+				return "", 0
+			}
+			q = v
+		}
+	}
+	return name, q
+}
@@ -12,6 +12,14 @@ const (
 type Error struct {
 	code    int
 	message string
+	details []*FieldViolation
+}
+
+// FieldViolation 字段校验错误详情
+type FieldViolation struct {
+	Field   string `json:"field" xml:"field"`
+	Rule    string `json:"rule" xml:"rule"`
+	Message string `json:"message" xml:"message"`
 }
 
 func (e *Error) Error() string { return fmt.Sprintf("%d - %s", e.code, e.message) }
@@ -28,6 +36,19 @@ func (e *Error) Message() string {
 	return e.message
 }
 
+func (e *Error) Details() []*FieldViolation {
+	return e.details
+}
+
+// WithDetails 返回携带字段错误详情的新error 不修改原error
+func (e *Error) WithDetails(details ...*FieldViolation) *Error {
+	return &Error{
+		code:    e.code,
+		message: e.message,
+		details: append(append([]*FieldViolation(nil), e.details...), details...),
+	}
+}
+
 func (e *Error) Is(err error) bool {
 	if se := new(Error); errors.As(err, &se) {
 		return se.code == e.code
@@ -81,5 +102,9 @@ func Cause(err error) *Error {
 	if _, ok := err.(*Error); ok {
 		return err.(*Error)
 	}
+	// 兼容通过Unwrap包装的错误 例如http client的HTTPError
+	if se := new(Error); errors.As(err, &se) {
+		return se
+	}
 	return New(unknownCode, err.Error())
 }
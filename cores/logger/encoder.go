@@ -51,3 +51,8 @@ func traceEncoder(ctx context.Context) string {
 	}
 	return traceID
 }
+
+// TraceID 获取context中的链路id 未开启链路追踪时为空
+func TraceID(ctx context.Context) string {
+	return traceEncoder(ctx)
+}
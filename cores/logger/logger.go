@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	gCtx "github.com/curry-mz/sagittarius-golang/context"
+
 	rotate "github.com/lestrrat-go/file-rotatelogs"
 )
 
@@ -187,6 +189,9 @@ func (l *Logger) write(ctx context.Context, level Level, format string, args ...
 		if traceID := traceEncoder(ctx); traceID != "" {
 			data["trace_id"] = traceID
 		}
+		if requestID := gCtx.RequestID(ctx); requestID != "" {
+			data["request_id"] = requestID
+		}
 		bs, err := json.Marshal(data)
 		if err != nil {
 			return
@@ -216,6 +221,9 @@ func (l *Logger) write(ctx context.Context, level Level, format string, args ...
 		if traceID := traceEncoder(ctx); traceID != "" {
 			buf.WriteString("(" + traceID + ")")
 		}
+		if requestID := gCtx.RequestID(ctx); requestID != "" {
+			buf.WriteString("[" + requestID + "]")
+		}
 	}
 	if buf.Len() > 0 {
 		buf.WriteString("\n")
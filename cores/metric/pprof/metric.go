@@ -7,6 +7,8 @@ import (
 	"net/http/pprof"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Option func(*Metric)
@@ -41,6 +43,7 @@ func InitMetric(ctx context.Context, opts ...Option) *Metric {
 		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.Handle("/metrics", promhttp.Handler())
 
 		_m = &Metric{
 			ctx:  ctx,
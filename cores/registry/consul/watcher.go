@@ -34,11 +34,12 @@ func newWatcher(ctx context.Context, key string, sn string, proto string, client
 	return w, nil
 }
 
-// Start 开始监听
+// Start 开始监听 首次立即返回 之后阻塞到节点变化或Stop
 func (w *watcher) Start() ([]*registry.Service, error) {
-	fn := func() ([]*registry.Service, error) {
+	fn := func() ([]*registry.Service, bool, error) {
 		key := fmt.Sprintf("%s-%s", w.key, w.proto)
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*90)
+		// 使用watcher的context Stop时中断阻塞查询
+		ctx, cancel := context.WithTimeout(w.ctx, time.Second*90)
 		opts := &api.QueryOptions{
 			WaitIndex: w.index,
 			WaitTime:  time.Second * 30,
@@ -47,39 +48,49 @@ func (w *watcher) Start() ([]*registry.Service, error) {
 		entries, meta, err := w.cli.Health().Service(key, "", true, opts)
 		cancel()
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
+		// 阻塞查询超时 节点未变化
+		if meta.LastIndex == w.index {
+			return nil, false, nil
+		}
+		w.index = meta.LastIndex
 		var srvs []*registry.Service
-		if len(entries) != 0 && meta.LastIndex != w.index {
-			for _, entry := range entries {
-				srv := &registry.Service{
-					ID:          entry.Service.ID,
-					Namespace:   entry.Service.Meta["namespace"],
-					Product:     entry.Service.Meta["product"],
-					ServiceName: entry.Service.Meta["serviceName"],
-					Tags:        strings.Join(entry.Service.Tags, ","),
-				}
-				if srv.ServiceName != w.serviceName {
-					continue
-				}
-				if !strings.Contains(srv.Tags, env.GetRunEnv()) {
-					continue
-				}
-				srv.Hosts = map[string]string{
-					w.proto: fmt.Sprintf("%s:%d", entry.Service.Address, entry.Service.Port),
-				}
-				srvs = append(srvs, srv)
+		for _, entry := range entries {
+			srv := &registry.Service{
+				ID:          entry.Service.ID,
+				Namespace:   entry.Service.Meta["namespace"],
+				Product:     entry.Service.Meta["product"],
+				ServiceName: entry.Service.Meta["serviceName"],
+				Tags:        strings.Join(entry.Service.Tags, ","),
+			}
+			if srv.ServiceName != w.serviceName {
+				continue
+			}
+			if !strings.Contains(srv.Tags, env.GetRunEnv()) {
+				continue
+			}
+			srv.Hosts = map[string]string{
+				w.proto: fmt.Sprintf("%s:%d", entry.Service.Address, entry.Service.Port),
 			}
-			w.index = meta.LastIndex
+			srvs = append(srvs, srv)
 		}
-		return srvs, nil
+		return srvs, true, nil
 	}
 
-	select {
-	case <-w.ctx.Done():
-		return nil, w.ctx.Err()
-	default:
-		return fn()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return nil, w.ctx.Err()
+		default:
+		}
+		srvs, changed, err := fn()
+		if w.ctx.Err() != nil {
+			return nil, w.ctx.Err()
+		}
+		if err != nil || changed {
+			return srvs, err
+		}
 	}
 }
 
@@ -3,16 +3,27 @@ package http
 import (
 	"encoding/json"
 	"encoding/xml"
+	"mime/multipart"
 	"net/url"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/form/v4"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
+var _protojsonUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
 var (
 	_binders = map[string]IBinder{
 		"application/x-www-form-urlencoded": newFormBinder(),
 		"application/json":                  newJsonBinder(),
 		"application/xml":                   newXmlBinder(),
+		"multipart/form-data":               newMultipartBinder(),
+		"application/x-protobuf":            newProtoBinder(),
+		"application/protobuf":              newProtoBinder(),
 		"query":                             newQueryBinder(),
 	}
 )
@@ -66,7 +77,11 @@ func newJsonBinder() *JsonBinder {
 	return &JsonBinder{}
 }
 
+// Unmarshal proto.Message使用protojson解析 忽略未知字段
 func (jb *JsonBinder) Unmarshal(c *Context, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return _protojsonUnmarshal.Unmarshal(c.reqBody, m)
+	}
 	return json.Unmarshal(c.reqBody, v)
 }
 
@@ -79,3 +94,83 @@ func newXmlBinder() *XmlBinder {
 func (xb *XmlBinder) Unmarshal(c *Context, v interface{}) error {
 	return xml.Unmarshal(c.reqBody, v)
 }
+
+type ProtoBinder struct{}
+
+func newProtoBinder() *ProtoBinder {
+	return &ProtoBinder{}
+}
+
+func (pb *ProtoBinder) Unmarshal(c *Context, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("protobuf bind value must be proto.Message")
+	}
+	return proto.Unmarshal(c.reqBody, m)
+}
+
+type MultipartBinder struct {
+	decoder *form.Decoder
+}
+
+func newMultipartBinder() *MultipartBinder {
+	d := form.NewDecoder()
+	d.SetTagName("json")
+	return &MultipartBinder{
+		decoder: d,
+	}
+}
+
+// Unmarshal 表单字段按json tag绑定 *multipart.FileHeader和[]*multipart.FileHeader字段绑定上传文件
+func (mb *MultipartBinder) Unmarshal(c *Context, v interface{}) error {
+	mf, err := c.MultipartForm()
+	if err != nil {
+		return err
+	}
+	if err = mb.decoder.Decode(v, url.Values(mf.Value)); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("multipart bind value must be a struct pointer")
+	}
+	bindFiles(rv.Elem(), mf.File)
+	return nil
+}
+
+var (
+	_fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	_fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+func bindFiles(rv reflect.Value, files map[string][]*multipart.FileHeader) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindFiles(fv, files)
+			continue
+		}
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fhs := files[name]
+		if len(fhs) == 0 {
+			continue
+		}
+		switch field.Type {
+		case _fileHeaderType:
+			fv.Set(reflect.ValueOf(fhs[0]))
+		case _fileHeadersType:
+			fv.Set(reflect.ValueOf(fhs))
+		}
+	}
+}
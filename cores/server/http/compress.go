@@ -0,0 +1,248 @@
+package http
+
+import (
+	"bufio"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/curry-mz/sagittarius-golang/cores/compress"
+
+	"github.com/pkg/errors"
+)
+
+///////////////////////////////////////////
+// 响应压缩中间件
+// 按Accept-Encoding协商br/gzip/deflate 响应达到最小长度且Content-Type在白名单内时压缩
+// 已设置Content-Encoding(例如预压缩静态文件)或Range响应不处理
+///////////////////////////////////////////
+
+type CompressOption func(*compressOptions)
+
+type compressOptions struct {
+	minSize   int
+	level     int
+	encodings []string
+	types     []string
+}
+
+// CompressMinSize 最小压缩长度 默认1KB
+func CompressMinSize(n int) CompressOption {
+	return func(o *compressOptions) {
+		o.minSize = n
+	}
+}
+
+// CompressLevel 压缩级别 默认使用各编码的默认级别
+func CompressLevel(level int) CompressOption {
+	return func(o *compressOptions) {
+		o.level = level
+	}
+}
+
+// CompressEncodings 支持的编码 q值相同时按顺序优先 默认br, gzip, deflate
+func CompressEncodings(encodings ...string) CompressOption {
+	return func(o *compressOptions) {
+		o.encodings = encodings
+	}
+}
+
+// CompressTypes Content-Type白名单 以/结尾时匹配前缀 例如text/
+func CompressTypes(types ...string) CompressOption {
+	return func(o *compressOptions) {
+		o.types = types
+	}
+}
+
+func (o *compressOptions) allowType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range o.types {
+		if strings.HasSuffix(t, "/") {
+			if strings.HasPrefix(mt, t) {
+				return true
+			}
+		} else if mt == t {
+			return true
+		}
+	}
+	return false
+}
+
+func Compress(opts ...CompressOption) core {
+	o := &compressOptions{
+		minSize:   1024,
+		level:     compress.DefaultLevel,
+		encodings: []string{compress.Brotli, compress.Gzip, compress.Deflate},
+		types: []string{
+			"text/html",
+			"text/plain",
+			"text/css",
+			"text/csv",
+			"text/javascript",
+			"text/xml",
+			"application/json",
+			"application/problem+json",
+			"application/xml",
+			"application/problem+xml",
+			"application/javascript",
+			"application/x-www-form-urlencoded",
+			"application/x-ndjson",
+			"image/svg+xml",
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(c *Context) {
+		c.w.Header().Add("Vary", "Accept-Encoding")
+		if c.r.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		encoding := compress.Negotiate(c.r.Header.Get("Accept-Encoding"), o.encodings)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: c.w,
+			opts:           o,
+			encoding:       encoding,
+		}
+		c.w = cw
+		defer func() {
+			_ = cw.close()
+			c.w = cw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// compressWriter 缓存首段数据直到可以判断是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	opts     *compressOptions
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	ew       *compress.Writer
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(bs []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, bs...)
+		if len(w.buf) < w.opts.minSize {
+			return len(bs), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(bs), nil
+	}
+	if w.ew != nil {
+		return w.ew.Write(bs)
+	}
+	return w.ResponseWriter.Write(bs)
+}
+
+// decide 判断是否压缩并写出缓存数据 full为false时表示数据未达到最小长度
+func (w *compressWriter) decide(full bool) error {
+	w.decided = true
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if full && w.shouldCompress() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		ew, err := compress.NewWriter(w.encoding, w.ResponseWriter, w.opts.level)
+		if err != nil {
+			return err
+		}
+		w.ew = ew
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.ew != nil {
+		_, err = w.ew.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	h := w.ResponseWriter.Header()
+	switch {
+	case w.status < http.StatusOK,
+		w.status == http.StatusNoContent,
+		w.status == http.StatusNotModified,
+		w.status == http.StatusPartialContent:
+		return false
+	case h.Get("Content-Encoding") != "",
+		h.Get("Content-Range") != "":
+		return false
+	}
+	return w.opts.allowType(h.Get("Content-Type"))
+}
+
+// Flush 流式响应flush时立即决定 不受最小长度限制
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.ew != nil {
+		_ = w.ew.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http response writer not support hijack")
+	}
+	return h.Hijack()
+}
+
+func (w *compressWriter) close() error {
+	if !w.decided {
+		// handler未写入时不补写状态码 由后续逻辑处理
+		if w.status == 0 {
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.ew != nil {
+		return w.ew.Close()
+	}
+	return nil
+}
@@ -1,19 +1,36 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"encoding/xml"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 
 	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+	"github.com/curry-mz/sagittarius-golang/cores/server/render"
+	"github.com/curry-mz/sagittarius-golang/cores/validator"
 
 	"github.com/go-playground/form/v4"
 	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 type core func(*Context)
 
+var _defaultRenderer = render.NewEnvelope()
+
 type Context struct {
 	ctx      context.Context
 	r        *http.Request
@@ -23,6 +40,12 @@ type Context struct {
 	reqBody  []byte
 	reqData  interface{}
 	respData interface{}
+	params   params
+	fullPath string
+	code     string
+	form     *multipart.Form
+	stream   *stream
+	rw       responseWriter
 
 	cores []core
 	index int8
@@ -52,6 +75,11 @@ func (c *Context) reset() *Context {
 	c.respData = nil
 	c.reqData = nil
 	c.reqBody = nil
+	c.params = c.params[:0]
+	c.fullPath = ""
+	c.code = ""
+	c.form = nil
+	c.stream = nil
 	return c
 }
 
@@ -103,6 +131,17 @@ func (c *Context) Path() string {
 	return c.r.URL.Path
 }
 
+// FullPath 匹配的路由模板 例如/user/:id 未匹配时为空
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// Param 获取路由参数 例如/user/:id中的id
+func (c *Context) Param(name string) string {
+	v, _ := c.params.get(name)
+	return v
+}
+
 func (c *Context) Abort() {
 	c.index = int8(len(c.cores))
 }
@@ -134,13 +173,7 @@ func (c *Context) Bind(atom interface{}, v interface{}) error {
 	}
 	// 解析body
 	if len(c.reqBody) > 0 {
-		var accept string
-		for _, ct := range c.Request().Header["Content-Type"] {
-			if ct != "" {
-				accept = ct
-				break
-			}
-		}
+		accept := c.ContentType()
 		if accept == "" {
 			return errors.New("request head Content-Type not support")
 		}
@@ -154,14 +187,99 @@ func (c *Context) Bind(atom interface{}, v interface{}) error {
 	if v != nil {
 		c.reqData = v
 	}
-	return nil
+	// 参数校验
+	if err := validator.Validate(atom); err != nil {
+		return err
+	}
+	return validator.Validate(v)
+}
+
+// ContentType 请求的media type 不包含charset/boundary等参数
+func (c *Context) ContentType() string {
+	for _, ct := range c.Request().Header["Content-Type"] {
+		if ct == "" {
+			continue
+		}
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return ct
+		}
+		return mt
+	}
+	return ""
+}
+
+// MultipartForm 解析multipart/form-data 超过engine配置内存阈值的文件写入os.TempDir() 请求结束后删除
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.form != nil {
+		return c.form, nil
+	}
+	_, ps, err := mime.ParseMediaType(c.Request().Header.Get("Content-Type"))
+	if err != nil {
+		return nil, http.ErrNotMultipart
+	}
+	boundary := ps["boundary"]
+	if boundary == "" {
+		return nil, http.ErrMissingBoundary
+	}
+	var body io.Reader = c.r.Body
+	if c.reqBody != nil {
+		body = bytes.NewReader(c.reqBody)
+	}
+	maxMemory := int64(_defaultMultipartMemory)
+	if c.srv != nil {
+		maxMemory = c.srv.multipartMemory
+	}
+	mf, err := multipart.NewReader(body, boundary).ReadForm(maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	c.form = mf
+	c.r.MultipartForm = mf
+	return mf, nil
+}
+
+// FormFile 获取上传文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	mf, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if fhs := mf.File[name]; len(fhs) > 0 {
+		return fhs[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile 保存上传文件到dst 目录不存在时自动创建
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, src)
+	return err
 }
 
 func (c *Context) HttpError(code int, message string) error {
 	c.w.Header().Add("Content-Type", "text/plain")
 	c.w.WriteHeader(code)
 
-	c.buildRespData(code, -1, message, nil)
+	c.respData = map[string]interface{}{
+		"httpCode": code,
+		"message":  message,
+	}
 	_, err := c.w.Write([]byte(message))
 	if err != nil {
 		return err
@@ -228,154 +346,184 @@ func (c *Context) XmlCustom(data interface{}) error {
 }
 
 func (c *Context) JsonOK(data interface{}) error {
-	c.w.Header().Add("Content-Type", "application/json")
-	c.w.WriteHeader(http.StatusOK)
-
-	body := map[string]interface{}{
-		"status":  0,
-		"message": "success",
-	}
-	if data != nil {
-		body["data"] = data
-	}
-	bs, err := json.Marshal(body)
-	if err != nil {
-		return err
-	}
-	c.buildRespData(http.StatusOK, 0, "", data)
-	_, err = c.w.Write(bs)
-	if err != nil {
-		return err
-	}
-	return nil
+	return c.renderOK(data, "json")
 }
 
 func (c *Context) FormOK(data interface{}) error {
-	c.w.Header().Add("Content-Type", "application/x-www-form-urlencoded")
-	c.w.WriteHeader(http.StatusOK)
-
-	body := map[string]interface{}{
-		"status":  0,
-		"message": "success",
-	}
-	if data != nil {
-		body["data"] = data
-	}
-	e := form.NewEncoder()
-	vs, err := e.Encode(body)
-	if err != nil {
-		return err
-	}
-	c.buildRespData(http.StatusOK, 0, "", data)
-	_, err = c.w.Write([]byte(vs.Encode()))
-	if err != nil {
-		return err
-	}
-	return nil
+	return c.renderOK(data, "form")
 }
 
 func (c *Context) XmlOK(data interface{}) error {
-	c.w.Header().Add("Content-Type", "application/xml")
-	c.w.WriteHeader(http.StatusOK)
-
-	body := map[string]interface{}{
-		"status":  0,
-		"message": "success",
-	}
-	if data != nil {
-		body["data"] = data
-	}
-	bs, err := xml.Marshal(body)
-	if err != nil {
-		return err
-	}
-	c.buildRespData(http.StatusOK, 0, "", data)
-	_, err = c.w.Write(bs)
-	if err != nil {
-		return err
-	}
-	return nil
+	return c.renderOK(data, "xml")
 }
 
 func (c *Context) JsonErr(err error) error {
-	c.w.Header().Add("Content-Type", "application/json")
-	c.w.WriteHeader(http.StatusOK)
+	return c.renderErr(err, "json")
+}
 
-	ge := gErrors.Cause(err)
-	body := map[string]interface{}{
-		"status":  ge.Code(),
-		"message": ge.Message(),
-	}
-	bs, err := json.Marshal(body)
-	if err != nil {
-		return err
-	}
-	c.buildRespData(http.StatusOK, ge.Code(), ge.Message(), nil)
-	_, err = c.w.Write(bs)
-	if err != nil {
-		return err
+func (c *Context) FormErr(err error) error {
+	return c.renderErr(err, "form")
+}
+
+func (c *Context) XmlErr(err error) error {
+	return c.renderErr(err, "xml")
+}
+
+func (c *Context) ProtoOK(data proto.Message) error {
+	return c.renderOK(data, "proto")
+}
+
+func (c *Context) ProtoErr(err error) error {
+	return c.renderErr(err, "proto")
+}
+
+// OK 按请求Accept选择json/xml/form/protobuf 未指定时使用json
+func (c *Context) OK(data interface{}) error {
+	format := negotiateFormat(c.r.Header.Get("Accept"))
+	if _, ok := data.(proto.Message); format == "proto" && !ok {
+		format = "json"
 	}
-	return nil
+	return c.renderOK(data, format)
 }
 
-func (c *Context) FormErr(err error) error {
-	c.w.Header().Add("Content-Type", "application/x-www-form-urlencoded")
-	c.w.WriteHeader(http.StatusOK)
+// Err 按请求Accept选择json/xml/form/protobuf 未指定时使用json
+func (c *Context) Err(err error) error {
+	return c.renderErr(err, negotiateFormat(c.r.Header.Get("Accept")))
+}
 
+func (c *Context) renderOK(data interface{}, format string) error {
+	c.code = "0"
+	switch format {
+	case "proto":
+		m, ok := data.(proto.Message)
+		if !ok {
+			return errors.New("protobuf response data must be proto.Message")
+		}
+		// protobuf不包含外层结构 仅使用渲染的状态码
+		rp := c.renderer().OK(c.ctx, nil)
+		return c.render(&render.Response{HttpCode: rp.HttpCode, Body: m}, format)
+	case "json":
+		// proto.Message使用protojson序列化
+		if m, ok := data.(proto.Message); ok {
+			bs, err := protojson.Marshal(m)
+			if err != nil {
+				return err
+			}
+			data = json.RawMessage(bs)
+		}
+	}
+	return c.render(c.renderer().OK(c.ctx, data), format)
+}
+
+func (c *Context) renderErr(err error, format string) error {
 	ge := gErrors.Cause(err)
-	body := map[string]interface{}{
-		"status":  ge.Code(),
-		"message": ge.Message(),
+	c.code = strconv.Itoa(ge.Code())
+	rp := c.renderer().Err(c.ctx, ge)
+	if format == "proto" {
+		st, e := statusProto(ge)
+		if e != nil {
+			return e
+		}
+		rp = &render.Response{HttpCode: rp.HttpCode, Body: st}
 	}
-	e := form.NewEncoder()
-	vs, err := e.Encode(body)
-	if err != nil {
-		return err
+	return c.render(rp, format)
+}
+
+// statusProto 错误转换为google.rpc.Status 字段错误详情使用google.rpc.BadRequest
+func statusProto(ge *gErrors.Error) (*spb.Status, error) {
+	st := &spb.Status{
+		Code:    int32(ge.Code()),
+		Message: ge.Message(),
 	}
-	c.buildRespData(http.StatusOK, ge.Code(), ge.Message(), nil)
-	_, err = c.w.Write([]byte(vs.Encode()))
+	if len(ge.Details()) == 0 {
+		return st, nil
+	}
+	br := &errdetails.BadRequest{}
+	for _, d := range ge.Details() {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       d.Field,
+			Description: d.Message,
+		})
+	}
+	detail, err := anypb.New(br)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	st.Details = append(st.Details, detail)
+	return st, nil
 }
 
-func (c *Context) XmlErr(err error) error {
-	c.w.Header().Add("Content-Type", "application/xml")
-	c.w.WriteHeader(http.StatusOK)
+func (c *Context) renderer() render.ResponseRenderer {
+	if c.srv != nil && c.srv.renderer != nil {
+		return c.srv.renderer
+	}
+	return _defaultRenderer
+}
 
-	ge := gErrors.Cause(err)
-	body := map[string]interface{}{
-		"status":  ge.Code(),
-		"message": ge.Message(),
+// render 按格式编码渲染结果 problem details使用application/problem+json(xml)
+func (c *Context) render(rp *render.Response, format string) error {
+	var (
+		contentType string
+		bs          []byte
+		err         error
+	)
+	switch format {
+	case "json":
+		contentType = "application/json"
+		if rp.Problem {
+			contentType = "application/problem+json"
+		}
+		if rp.Body != nil {
+			bs, err = json.Marshal(rp.Body)
+		}
+	case "xml":
+		contentType = "application/xml"
+		if rp.Problem {
+			contentType = "application/problem+xml"
+		}
+		if rp.Body != nil {
+			bs, err = xml.Marshal(rp.Body)
+		}
+	case "proto":
+		contentType = "application/x-protobuf"
+		if m, ok := rp.Body.(proto.Message); ok {
+			// 标明消息类型 客户端据此识别google.rpc.Status错误响应
+			contentType += "; proto=" + string(m.ProtoReflect().Descriptor().FullName())
+			bs, err = proto.Marshal(m)
+		}
+	default:
+		contentType = "application/x-www-form-urlencoded"
+		if rp.Body != nil {
+			var vs url.Values
+			if vs, err = form.NewEncoder().Encode(rp.Body); err == nil {
+				bs = []byte(vs.Encode())
+			}
+		}
 	}
-	bs, err := xml.Marshal(body)
 	if err != nil {
 		return err
 	}
-	c.buildRespData(http.StatusOK, ge.Code(), ge.Message(), nil)
-	_, err = c.w.Write(bs)
-	if err != nil {
-		return err
+	httpCode := rp.HttpCode
+	if httpCode == 0 {
+		httpCode = http.StatusOK
 	}
-	return nil
+	c.w.Header().Add("Content-Type", contentType)
+	c.w.WriteHeader(httpCode)
+
+	c.buildRespData(httpCode, rp.Body)
+	if len(bs) == 0 {
+		return nil
+	}
+	_, err = c.w.Write(bs)
+	return err
 }
 
-func (c *Context) buildRespData(httpCode int, status int, message string, body interface{}) {
+func (c *Context) buildRespData(httpCode int, body interface{}) {
 	data := map[string]interface{}{
 		"httpCode": httpCode,
 	}
-	if httpCode != http.StatusOK {
-		data["message"] = message
-	} else {
-		bd := map[string]interface{}{
-			"status":  status,
-			"message": message,
-		}
-		if status == 0 && body != nil {
-			bd["data"] = body
-		}
-		data["body"] = bd
+	if body != nil {
+		data["body"] = body
 	}
 	c.respData = data
 }
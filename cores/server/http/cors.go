@@ -0,0 +1,170 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////
+// 跨域中间件
+// 需通过Group.Use挂载 OPTIONS预检请求只会执行分组中间件
+///////////////////////////////////////////
+
+type CORSOption func(*corsOptions)
+
+type corsOptions struct {
+	origins       []string
+	patterns      []*regexp.Regexp
+	allowAll      bool
+	methods       []string
+	headers       []string
+	exposeHeaders []string
+	credentials   bool
+	maxAge        time.Duration
+}
+
+// CORSOrigins 允许的域名 例如https://www.example.com "*"为允许所有域名
+func CORSOrigins(origins ...string) CORSOption {
+	return func(o *corsOptions) {
+		for _, origin := range origins {
+			if origin == "*" {
+				o.allowAll = true
+				continue
+			}
+			o.origins = append(o.origins, strings.ToLower(origin))
+		}
+	}
+}
+
+// CORSOriginPatterns 允许的域名通配 例如https://*.example.com
+func CORSOriginPatterns(patterns ...string) CORSOption {
+	return func(o *corsOptions) {
+		for _, p := range patterns {
+			expr := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(p)), `\*`, `[^/]*`)
+			o.patterns = append(o.patterns, regexp.MustCompile("^"+expr+"$"))
+		}
+	}
+}
+
+// CORSMethods 允许的请求方法
+func CORSMethods(methods ...string) CORSOption {
+	return func(o *corsOptions) {
+		o.methods = methods
+	}
+}
+
+// CORSHeaders 允许的请求头
+func CORSHeaders(headers ...string) CORSOption {
+	return func(o *corsOptions) {
+		o.headers = headers
+	}
+}
+
+// CORSExposeHeaders 允许浏览器读取的响应头
+func CORSExposeHeaders(headers ...string) CORSOption {
+	return func(o *corsOptions) {
+		o.exposeHeaders = headers
+	}
+}
+
+// CORSCredentials 是否允许携带cookie等凭证
+func CORSCredentials(allow bool) CORSOption {
+	return func(o *corsOptions) {
+		o.credentials = allow
+	}
+}
+
+// CORSMaxAge 预检结果缓存时间
+func CORSMaxAge(d time.Duration) CORSOption {
+	return func(o *corsOptions) {
+		o.maxAge = d
+	}
+}
+
+func (o *corsOptions) allowOrigin(origin string) bool {
+	if o.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, v := range o.origins {
+		if v == origin {
+			return true
+		}
+	}
+	for _, p := range o.patterns {
+		if p.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func CORS(opts ...CORSOption) core {
+	o := &corsOptions{
+		methods: []string{
+			http.MethodGet, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodHead,
+		},
+		headers: []string{"Content-Type", "Authorization"},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	methods := strings.Join(o.methods, ", ")
+	headers := strings.Join(o.headers, ", ")
+	exposeHeaders := strings.Join(o.exposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(o.maxAge / time.Second))
+
+	return func(c *Context) {
+		origin := c.Request().Header.Get("Origin")
+		if origin == "" {
+			// 非跨域请求
+			c.Next()
+			return
+		}
+		preflight := c.Request().Method == http.MethodOptions &&
+			c.Request().Header.Get("Access-Control-Request-Method") != ""
+
+		h := c.Writer().Header()
+		h.Add("Vary", "Origin")
+		if !o.allowOrigin(origin) {
+			if preflight {
+				c.w.WriteHeader(http.StatusForbidden)
+				c.Abort()
+				return
+			}
+			// 不写入跨域头 由浏览器拦截
+			c.Next()
+			return
+		}
+		if o.allowAll && !o.credentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if o.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			if o.maxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.w.WriteHeader(http.StatusNoContent)
+			c.Abort()
+			return
+		}
+		if exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+///////////////////////////////////////////
+// prometheus指标
+// 路由按模板统计(例如/user/:id) 未匹配路由的404/405不经过中间件 不统计
+// body超限/解码失败等提前拒绝的请求同样经过分组中间件 按路由统计
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http_server",
+		Name:      "requests_total",
+		Help:      "Total number of http requests.",
+	}, []string{"method", "route", "code", "biz_code"})
+
+	_requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http_server",
+		Name:      "request_duration_seconds",
+		Help:      "Histogram of http request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	_requestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "http_server",
+		Name:      "requests_in_flight",
+		Help:      "Number of http requests being served.",
+	}, []string{"method", "route"})
+
+	_requestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http_server",
+		Name:      "request_size_bytes",
+		Help:      "Histogram of http request body size.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"method", "route"})
+
+	_responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http_server",
+		Name:      "response_size_bytes",
+		Help:      "Histogram of http response body size.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"method", "route"})
+)
+
+// MetricsHandler 请求数/耗时/并发数/请求和响应大小 指标注册到prometheus默认registry
+func MetricsHandler() core {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_requestsTotal, _requestDuration, _requestsInFlight, _requestSize, _responseSize)
+	})
+	return func(c *Context) {
+		method := c.Request().Method
+		route := c.FullPath()
+		inFlight := _requestsInFlight.WithLabelValues(method, route)
+		inFlight.Inc()
+		start := time.Now()
+
+		defer func() {
+			inFlight.Dec()
+			status := c.rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			code := strconv.Itoa(status)
+			_requestsTotal.WithLabelValues(method, route, code, c.code).Inc()
+			_requestDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+
+			size := c.Request().ContentLength
+			if size < 0 {
+				size = int64(len(c.reqBody))
+			}
+			_requestSize.WithLabelValues(method, route).Observe(float64(size))
+			_responseSize.WithLabelValues(method, route).Observe(float64(c.rw.size))
+		}()
+		c.Next()
+	}
+}
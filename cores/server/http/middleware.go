@@ -38,6 +38,19 @@ func PanicHandler(lgr *logger.Logger) core {
 	}
 }
 
+// RequestIDHandler 读取X-Request-ID 不存在或不合法时生成 并写入响应header
+func RequestIDHandler() core {
+	return func(c *Context) {
+		rid := gCtx.GetRequestIDHttpHeader(c.Request().Header)
+		if !gCtx.ValidRequestID(rid) {
+			rid = gCtx.NewRequestID()
+		}
+		c.ctx = gCtx.NewRequestIDContext(c.ctx, rid)
+		gCtx.SetRequestIDHttpHeader(c.w.Header(), rid)
+		c.Next()
+	}
+}
+
 func TracingHandler(tracer opentracing.Tracer) core {
 	return func(c *Context) {
 		spanContext, err := tracer.Extract(
@@ -85,9 +98,10 @@ func LogHandler(lgr *logger.Logger, requestEnable bool) core {
 
 		defer func() {
 			logData := map[string]interface{}{
-				"Peer":   td,
-				"Method": c.Request().URL.String(),
-				"Cost":   fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
+				"Peer":      td,
+				"RequestID": gCtx.RequestID(c.ctx),
+				"Method":    c.Request().URL.String(),
+				"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
 			}
 			if requestEnable {
 				logData["Request"] = c.reqData
@@ -95,6 +109,9 @@ func LogHandler(lgr *logger.Logger, requestEnable bool) core {
 			if c.respData != nil {
 				logData["Response"] = c.respData
 			}
+			if c.stream != nil {
+				logData["Stream"] = c.stream.stat()
+			}
 			bs, e := json.Marshal(logData)
 			if e != nil {
 				return
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// 响应格式对应的media type
+var _formats = map[string]string{
+	"application/json":                  "json",
+	"application/problem+json":          "json",
+	"application/xml":                   "xml",
+	"application/problem+xml":           "xml",
+	"text/xml":                          "xml",
+	"application/x-www-form-urlencoded": "form",
+	"application/x-protobuf":            "proto",
+	"application/protobuf":              "proto",
+}
+
+// negotiateFormat 按Accept的q值选择响应格式 无匹配时使用json
+func negotiateFormat(accept string) string {
+	var (
+		format = "json"
+		best   = 0.0
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mt, ps, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := ps["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		f, ok := _formats[mt]
+		if !ok || q <= best {
+			continue
+		}
+		format, best = f, q
+	}
+	return format
+}
@@ -0,0 +1,497 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"html/template"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+	"github.com/curry-mz/sagittarius-golang/cores/server/render"
+)
+
+///////////////////////////////////////////
+// 路由注册表与OpenAPI 3文档
+///////////////////////////////////////////
+
+// RouteInfo 注册的路由信息
+type RouteInfo struct {
+	Method string
+	// 路由模板 例如/user/:id
+	Path string
+	// 所属分组的basePath
+	Group       string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// query参数类型 对应Bind的atom
+	Query reflect.Type
+	// 请求类型 GET/HEAD/DELETE为query参数 其他为body
+	Request reflect.Type
+	// 响应类型 即JsonOK的data
+	Response reflect.Type
+}
+
+type DocOption func(*routeDoc)
+
+type routeDoc struct {
+	summary     string
+	description string
+	tags        []string
+	deprecated  bool
+	query       reflect.Type
+	request     reflect.Type
+	response    reflect.Type
+}
+
+// DocSummary 接口摘要
+func DocSummary(summary string) DocOption {
+	return func(d *routeDoc) {
+		d.summary = summary
+	}
+}
+
+// DocDescription 接口说明
+func DocDescription(description string) DocOption {
+	return func(d *routeDoc) {
+		d.description = description
+	}
+}
+
+// DocTags 接口分类 默认使用分组basePath
+func DocTags(tags ...string) DocOption {
+	return func(d *routeDoc) {
+		d.tags = tags
+	}
+}
+
+// DocDeprecated 标记为废弃
+func DocDeprecated() DocOption {
+	return func(d *routeDoc) {
+		d.deprecated = true
+	}
+}
+
+// DocQuery query参数类型 例如DocQuery(PageQuery{})
+func DocQuery(v interface{}) DocOption {
+	return func(d *routeDoc) {
+		d.query = reflect.TypeOf(v)
+	}
+}
+
+// DocRequest 请求类型 例如DocRequest(CreateUserReq{})
+func DocRequest(v interface{}) DocOption {
+	return func(d *routeDoc) {
+		d.request = reflect.TypeOf(v)
+	}
+}
+
+// DocResponse 响应数据类型 例如DocResponse(User{}) 文档按engine的响应渲染生成外层结构
+func DocResponse(v interface{}) DocOption {
+	return func(d *routeDoc) {
+		d.response = reflect.TypeOf(v)
+	}
+}
+
+// WithDoc 设置接口文档信息 返回新的Group 不影响当前Group
+// 例如 g.WithDoc(DocSummary("创建用户"), DocRequest(CreateUserReq{}), DocResponse(User{})).POST("/user", handler)
+func (g *Group) WithDoc(opts ...DocOption) *Group {
+	group := g.clone()
+	doc := &routeDoc{}
+	for _, opt := range opts {
+		opt(doc)
+	}
+	group.opts.doc = doc
+	return group
+}
+
+func (e *Engine) addRouteInfo(method string, path string, group string, doc *routeDoc) {
+	info := RouteInfo{
+		Method: method,
+		Path:   path,
+		Group:  group,
+	}
+	if doc != nil {
+		info.Summary = doc.summary
+		info.Description = doc.description
+		info.Tags = doc.tags
+		info.Deprecated = doc.deprecated
+		info.Query = doc.query
+		info.Request = doc.request
+		info.Response = doc.response
+	}
+	e.routesMu.Lock()
+	e.routes = append(e.routes, info)
+	e.routesMu.Unlock()
+}
+
+// Routes 已注册的路由 按注册顺序
+func (e *Engine) Routes() []RouteInfo {
+	e.routesMu.Lock()
+	defer e.routesMu.Unlock()
+	routes := make([]RouteInfo, len(e.routes))
+	copy(routes, e.routes)
+	return routes
+}
+
+type OpenAPIOption func(*openAPIOptions)
+
+type openAPIOptions struct {
+	title       string
+	version     string
+	description string
+	servers     []string
+	uiPath      string
+}
+
+// OpenAPITitle 文档标题
+func OpenAPITitle(title string) OpenAPIOption {
+	return func(o *openAPIOptions) {
+		o.title = title
+	}
+}
+
+// OpenAPIVersion 接口版本 默认1.0.0
+func OpenAPIVersion(version string) OpenAPIOption {
+	return func(o *openAPIOptions) {
+		o.version = version
+	}
+}
+
+// OpenAPIDescription 文档说明
+func OpenAPIDescription(description string) OpenAPIOption {
+	return func(o *openAPIOptions) {
+		o.description = description
+	}
+}
+
+// OpenAPIServers 服务地址 例如https://api.example.com
+func OpenAPIServers(servers ...string) OpenAPIOption {
+	return func(o *openAPIOptions) {
+		o.servers = servers
+	}
+}
+
+// OpenAPIUI Swagger UI页面路径 为空时不注册
+func OpenAPIUI(path string) OpenAPIOption {
+	return func(o *openAPIOptions) {
+		o.uiPath = path
+	}
+}
+
+// OpenAPI 在path注册OpenAPI 3 json文档 文档在请求时根据当前注册的路由生成
+// 例如 e.OpenAPI("/openapi.json", OpenAPITitle("user"), OpenAPIUI("/docs"))
+func (e *Engine) OpenAPI(path string, opts ...OpenAPIOption) {
+	o := &openAPIOptions{
+		title:   "API",
+		version: "1.0.0",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	e.Group.GET(path, func(c *Context) {
+		bs, err := json.Marshal(e.openAPIDocument(o, path))
+		if err != nil {
+			_ = c.HttpError(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.w.Header().Set("Content-Type", "application/json")
+		c.w.WriteHeader(http.StatusOK)
+		_, _ = c.w.Write(bs)
+	})
+	if o.uiPath == "" {
+		return
+	}
+	// title在html中 url在js字符串中 分别转义
+	page := strings.NewReplacer(
+		"{{title}}", html.EscapeString(o.title),
+		"{{url}}", template.JSEscapeString(path),
+	).Replace(_swaggerUI)
+	e.Group.GET(o.uiPath, func(c *Context) {
+		c.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		c.w.WriteHeader(http.StatusOK)
+		_, _ = c.w.Write([]byte(page))
+	})
+}
+
+func (e *Engine) openAPIDocument(o *openAPIOptions, docPath string) map[string]interface{} {
+	reg := newSchemaRegistry()
+	paths := make(map[string]map[string]interface{})
+	tagSet := make(map[string]bool)
+	for _, route := range e.Routes() {
+		// 不包含文档自身
+		if route.Path == docPath || (o.uiPath != "" && route.Path == o.uiPath) {
+			continue
+		}
+		p, names := openAPIPath(route.Path)
+		if paths[p] == nil {
+			paths[p] = make(map[string]interface{})
+		}
+		tags := route.Tags
+		if len(tags) == 0 {
+			tag := strings.Trim(route.Group, "/")
+			if tag == "" {
+				tag = "default"
+			}
+			tags = []string{tag}
+		}
+		for _, tag := range tags {
+			tagSet[tag] = true
+		}
+		op := map[string]interface{}{
+			"tags":        tags,
+			"operationId": strings.ToLower(route.Method) + operationName(route.Path),
+			"responses":   e.openAPIResponses(reg, route.Response),
+		}
+		if route.Summary != "" {
+			op["summary"] = route.Summary
+		}
+		if route.Description != "" {
+			op["description"] = route.Description
+		}
+		if route.Deprecated {
+			op["deprecated"] = true
+		}
+		var parameters []map[string]interface{}
+		for _, name := range names {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   &schema{Type: "string"},
+			})
+		}
+		parameters = append(parameters, queryParameters(reg, route.Query)...)
+		if route.Request != nil {
+			switch route.Method {
+			case http.MethodGet, http.MethodHead, http.MethodDelete:
+				parameters = append(parameters, queryParameters(reg, route.Request)...)
+			default:
+				op["requestBody"] = requestBody(reg, route.Request)
+			}
+		}
+		if len(parameters) > 0 {
+			op["parameters"] = parameters
+		}
+		paths[p][strings.ToLower(route.Method)] = op
+	}
+	info := map[string]interface{}{
+		"title":   o.title,
+		"version": o.version,
+	}
+	if o.description != "" {
+		info["description"] = o.description
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+	}
+	if len(o.servers) > 0 {
+		var servers []map[string]string
+		for _, s := range o.servers {
+			servers = append(servers, map[string]string{"url": s})
+		}
+		doc["servers"] = servers
+	}
+	tags := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	var tagObjs []map[string]string
+	for _, tag := range tags {
+		tagObjs = append(tagObjs, map[string]string{"name": tag})
+	}
+	if len(tagObjs) > 0 {
+		doc["tags"] = tagObjs
+	}
+	if len(reg.schemas) > 0 {
+		doc["components"] = map[string]interface{}{
+			"schemas": reg.schemas,
+		}
+	}
+	return doc
+}
+
+// openAPIPath /user/:id/*path => /user/{id}/{path}
+func openAPIPath(path string) (string, []string) {
+	var names []string
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			names = append(names, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/"), names
+}
+
+// operationName /user/:id => UserId
+func operationName(path string) string {
+	var b strings.Builder
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '-' || r == '_' || r == '.'
+	}) {
+		b.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return b.String()
+}
+
+func queryParameters(reg *schemaRegistry, t reflect.Type) []map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	s := reg.structSchema(t)
+	required := make(map[string]bool)
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ps []map[string]interface{}
+	for _, name := range names {
+		ps = append(ps, map[string]interface{}{
+			"name":     name,
+			"in":       "query",
+			"required": required[name],
+			"schema":   s.Properties[name],
+		})
+	}
+	return ps
+}
+
+func requestBody(reg *schemaRegistry, t reflect.Type) map[string]interface{} {
+	contentType := "application/json"
+	if hasFileField(t) {
+		contentType = "multipart/form-data"
+	}
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{
+				"schema": reg.schemaOf(t),
+			},
+		},
+	}
+}
+
+func hasFileField(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i).Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft == _fileHeaderType.Elem() {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIResponses 通过engine的响应渲染生成样例 推断外层结构
+func (e *Engine) openAPIResponses(reg *schemaRegistry, t reflect.Type) map[string]interface{} {
+	ctx := context.Background()
+	var data interface{}
+	if t != nil {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		data = reflect.New(t).Interface()
+	}
+	ok := e.renderer.OK(ctx, data)
+	errRp := e.renderer.Err(ctx, gErrors.New(http.StatusBadRequest, "error").WithDetails(&gErrors.FieldViolation{}))
+
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "OK",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": bodySchema(reg, ok.Body, data, t),
+				},
+			},
+		},
+	}
+	contentType := "application/json"
+	if errRp.Problem {
+		contentType = "application/problem+json"
+	}
+	responses["default"] = map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{
+				"schema": bodySchema(reg, errRp.Body, nil, nil),
+			},
+		},
+	}
+	return responses
+}
+
+// bodySchema 渲染结果为Envelope时逐字段推断 data字段使用声明的响应类型
+func bodySchema(reg *schemaRegistry, body interface{}, data interface{}, t reflect.Type) *schema {
+	if data != nil && body == data {
+		return reg.schemaOf(t)
+	}
+	env, ok := body.(render.Envelope)
+	if !ok {
+		if body == nil {
+			return &schema{}
+		}
+		return reg.schemaOf(reflect.TypeOf(body))
+	}
+	s := &schema{
+		Type:       "object",
+		Properties: make(map[string]*schema),
+	}
+	for k, v := range env {
+		switch {
+		case v == nil:
+			s.Properties[k] = &schema{}
+		case data != nil && v == data:
+			s.Properties[k] = reg.schemaOf(t)
+		default:
+			s.Properties[k] = reg.schemaOf(reflect.TypeOf(v))
+		}
+	}
+	return s
+}
+
+const _swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <meta name="viewport" content="width=device-width, initial-scale=1" />
+  <title>{{title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "{{url}}", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
@@ -8,6 +8,7 @@ type Group struct {
 	cores    []core
 	basePath string
 	svr      *Engine
+	opts     routeOptions
 }
 
 func (g *Group) Group(basePath string) *Group {
@@ -15,7 +16,9 @@ func (g *Group) Group(basePath string) *Group {
 		svr:      g.svr,
 		cores:    nil,
 		basePath: basePath,
+		opts:     g.opts,
 	}
+	group.opts.doc = nil
 	if len(g.cores) > 0 {
 		group.cores = append(group.cores, g.cores...)
 	}
@@ -42,10 +45,41 @@ func (g *Group) Use(cores ...core) *Group {
 func (g *Group) handle(httpMethod, relativePath string, cores ...core) *Group {
 	absolutePath := g.calculateAbsolutePath(relativePath)
 	cores = g.combineHandlers(cores...)
-	g.svr.addRoute(httpMethod, absolutePath, cores...)
+	opts := g.opts
+	opts.mws = len(g.cores)
+	g.svr.addRoute(httpMethod, absolutePath, opts, cores...)
+	g.svr.addRouteInfo(httpMethod, absolutePath, g.basePath, opts.doc)
 	return g
 }
 
+func (g *Group) clone() *Group {
+	return &Group{
+		svr:      g.svr,
+		cores:    g.combineHandlers(),
+		basePath: g.basePath,
+		opts:     g.opts,
+	}
+}
+
+// WithMaxBodySize 设置body大小限制 n<=0为不限制 返回新的Group 不影响当前Group
+// 例如 g.WithMaxBodySize(10<<20).POST("/upload", handler)
+func (g *Group) WithMaxBodySize(n int64) *Group {
+	group := g.clone()
+	if n == 0 {
+		n = -1
+	}
+	group.opts.maxBodySize = n
+	return group
+}
+
+// WithStream 设置为流式路由 返回新的Group 不影响当前Group
+// 流式路由不提前读取和解密body 由handler自行读取Request().Body
+func (g *Group) WithStream() *Group {
+	group := g.clone()
+	group.opts.stream = true
+	return group
+}
+
 func (g *Group) POST(relativePath string, cores ...core) *Group {
 	return g.handle(http.MethodPost, relativePath, cores...)
 }
@@ -65,3 +99,11 @@ func (g *Group) PATCH(relativePath string, cores ...core) *Group {
 func (g *Group) PUT(relativePath string, cores ...core) *Group {
 	return g.handle(http.MethodPut, relativePath, cores...)
 }
+
+func (g *Group) HEAD(relativePath string, cores ...core) *Group {
+	return g.handle(http.MethodHead, relativePath, cores...)
+}
+
+func (g *Group) OPTIONS(relativePath string, cores ...core) *Group {
+	return g.handle(http.MethodOptions, relativePath, cores...)
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////
+// go类型转换为OpenAPI schema
+// 字段名使用json tag 校验规则读取validate tag
+///////////////////////////////////////////
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var _timeType = reflect.TypeOf(time.Time{})
+
+// schemaRegistry 具名结构体注册为components/schemas 通过$ref引用 支持递归类型
+type schemaRegistry struct {
+	schemas map[string]*schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+func (r *schemaRegistry) schemaOf(t reflect.Type) *schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case _timeType:
+		return &schema{Type: "string", Format: "date-time"}
+	case _fileHeaderType.Elem():
+		return &schema{Type: "string", Format: "binary"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		return &schema{Type: "array", Items: r.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: r.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return &schema{Ref: "#/components/schemas/" + r.register(t)}
+	}
+	// interface{}等任意类型
+	return &schema{}
+}
+
+func (r *schemaRegistry) register(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, exist := r.schemas[name]; exist {
+		// 不同包的同名类型
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	r.names[t] = name
+	// 先占位 防止递归类型死循环
+	r.schemas[name] = &schema{}
+	*r.schemas[name] = *r.structSchema(t)
+	return name
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *schema {
+	s := &schema{
+		Type:       "object",
+		Properties: make(map[string]*schema),
+	}
+	r.fields(t, s)
+	return s
+}
+
+func (r *schemaRegistry) fields(t reflect.Type, s *schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		// 匿名结构体字段展开
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			r.fields(ft, s)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := r.schemaOf(f.Type)
+		if applyRules(fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// fieldName json tag字段名 匿名字段无tag时返回空
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() && !f.Anonymous {
+		return "", false
+	}
+	tag := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if tag == "-" {
+		return "", false
+	}
+	if tag == "" && !f.Anonymous {
+		return f.Name, true
+	}
+	return tag, true
+}
+
+// applyRules 将validate规则转换为schema约束 返回是否必填
+func applyRules(s *schema, rules string) bool {
+	if rules == "" {
+		return false
+	}
+	var required bool
+	for _, rule := range strings.Split(rules, ",") {
+		// dive之后的规则作用于元素
+		if rule == "dive" {
+			break
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		var arg string
+		if len(kv) == 2 {
+			arg = kv[1]
+		}
+		switch kv[0] {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, enumValue(s.Type, v))
+			}
+		case "min", "gte":
+			limit(s, arg, true)
+		case "max", "lte":
+			limit(s, arg, false)
+		case "len":
+			limit(s, arg, true)
+			limit(s, arg, false)
+		}
+	}
+	return required
+}
+
+func enumValue(typ string, v string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func limit(s *schema, arg string, min bool) {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "integer", "number":
+		if min {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	case "string":
+		l := int(n)
+		if min {
+			s.MinLength = &l
+		} else {
+			s.MaxLength = &l
+		}
+	case "array":
+		l := int(n)
+		if min {
+			s.MinItems = &l
+		} else {
+			s.MaxItems = &l
+		}
+	}
+}
@@ -4,19 +4,28 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/curry-mz/sagittarius-golang/cores/compress"
 	"github.com/curry-mz/sagittarius-golang/cores/crypto"
+	"github.com/curry-mz/sagittarius-golang/cores/server/render"
 
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 )
 
+const (
+	_defaultMultipartMemory   = 32 << 20
+	_defaultReadHeaderTimeout = 10 * time.Second
+)
+
 type Option func(*Engine)
 
 func Addr(addr string) Option {
@@ -43,6 +52,58 @@ func UseH2C(h2c bool) Option {
 	}
 }
 
+// MaxBodySize 请求body大小限制 n<=0为不限制 默认不限制 上传等路由可用Group.WithMaxBodySize单独设置
+func MaxBodySize(n int64) Option {
+	return func(e *Engine) {
+		e.maxBodySize = n
+	}
+}
+
+// MultipartMemory multipart/form-data解析时文件的内存阈值 默认32MB
+// 超过后写入os.TempDir()临时文件 目录不可按engine配置 只能通过环境变量TMPDIR修改整个进程的临时目录
+func MultipartMemory(n int64) Option {
+	return func(e *Engine) {
+		e.multipartMemory = n
+	}
+}
+
+// Renderer 响应渲染 默认{"status","message","data"}
+func Renderer(r render.ResponseRenderer) Option {
+	return func(e *Engine) {
+		if r != nil {
+			e.renderer = r
+		}
+	}
+}
+
+// ReadTimeout 读取整个请求(包含body)的超时时间 默认不限制
+func ReadTimeout(d time.Duration) Option {
+	return func(e *Engine) {
+		e.readTimeout = d
+	}
+}
+
+// ReadHeaderTimeout 读取请求头的超时时间 默认10s
+func ReadHeaderTimeout(d time.Duration) Option {
+	return func(e *Engine) {
+		e.readHeaderTimeout = d
+	}
+}
+
+// WriteTimeout 写响应的超时时间 默认不限制 流式路由需谨慎设置
+func WriteTimeout(d time.Duration) Option {
+	return func(e *Engine) {
+		e.writeTimeout = d
+	}
+}
+
+// IdleTimeout keep-alive连接空闲超时时间 默认使用ReadTimeout
+func IdleTimeout(d time.Duration) Option {
+	return func(e *Engine) {
+		e.idleTimeout = d
+	}
+}
+
 func OnStop(f func()) Option {
 	return func(e *Engine) {
 		e.onStop = f
@@ -60,11 +121,27 @@ type Engine struct {
 	tlsCfg *tls.Config
 	crypto crypto.ICrypto
 	onStop func()
+
+	maxBodySize     int64
+	multipartMemory int64
+	renderer        render.ResponseRenderer
+
+	routesMu sync.Mutex
+	routes   []RouteInfo
+
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
 }
 
 func New(opts ...Option) *Engine {
 	e := &Engine{
-		tree: newTree(),
+		tree:            newTree(),
+		multipartMemory: _defaultMultipartMemory,
+		renderer:        render.NewEnvelope(),
+
+		readHeaderTimeout: _defaultReadHeaderTimeout,
 	}
 	group := &Group{
 		svr: e,
@@ -77,14 +154,18 @@ func New(opts ...Option) *Engine {
 		opt(e)
 	}
 	e.Server = &http.Server{
-		TLSConfig: e.tlsCfg,
-		Handler:   e,
+		TLSConfig:         e.tlsCfg,
+		Handler:           e,
+		ReadTimeout:       e.readTimeout,
+		ReadHeaderTimeout: e.readHeaderTimeout,
+		WriteTimeout:      e.writeTimeout,
+		IdleTimeout:       e.idleTimeout,
 	}
 	return e
 }
 
-func (e *Engine) addRoute(method string, path string, cores ...core) {
-	e.tree.addRoute(method, path, cores...)
+func (e *Engine) addRoute(method string, path string, opts routeOptions, cores ...core) {
+	e.tree.addRoute(method, path, opts, cores...)
 }
 
 func (e *Engine) NewGroup(basePath string) *Group {
@@ -127,43 +208,71 @@ func (e *Engine) Stop(ctx context.Context) error {
 func (e *Engine) handleHTTPRequest(c *Context) {
 	method := c.r.Method
 	path := c.r.URL.Path
-	if method == "OPTIONS" {
-		c.w.Header().Set("Access-Control-Allow-Origin", "*")                                // 允许所有域名访问
-		c.w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS") // 允许的请求方法
-		c.w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		c.w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-		c.Writer().WriteHeader(http.StatusOK)
-		return
+	nd := e.tree.getRoute(method, path, &c.params)
+	if nd == nil && method == http.MethodHead {
+		// HEAD请求未注册时使用GET路由 响应body由net/http丢弃
+		nd = e.tree.getRoute(http.MethodGet, path, &c.params)
 	}
-	if e.tree[method] == nil {
-		_ = c.HttpError(404, "page not found")
+	if nd == nil {
+		allows := e.tree.allowed(path)
+		if len(allows) == 0 {
+			_ = c.HttpError(404, "page not found")
+			return
+		}
+		if method == http.MethodOptions {
+			e.handleOptions(c, allows)
+			return
+		}
+		c.w.Header().Set("Allow", strings.Join(allows, ", "))
+		_ = c.HttpError(http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	root := e.tree[method]
-	if len(path) == 1 && path[0] == '/' {
-		c.cores = root.cores
-	} else {
-		ss := strings.Split(path, "/")
-		var ns []string
-		for _, s := range ss {
-			if s != "" {
-				ns = append(ns, s)
-			}
+	c.cores = nd.cores
+	c.fullPath = nd.fullPath
+	// body大小限制
+	limit := e.maxBodySize
+	if nd.opts.maxBodySize != 0 {
+		limit = nd.opts.maxBodySize
+	}
+	if limit > 0 {
+		if c.r.ContentLength > limit {
+			e.reject(c, nd, http.StatusRequestEntityTooLarge, "request body too large")
+			return
 		}
-		current := e.tree[method]
-		for _, s := range ns {
-			if _, has := current.children[s]; !has && method != "OPTIONS" {
-				_ = c.HttpError(404, "page not found")
-				return
-			}
-			current = current.children[s]
+		c.r.Body = http.MaxBytesReader(c.rw.ResponseWriter, c.r.Body, limit)
+	}
+	// 解码压缩的请求body 解码后同样受大小限制
+	if ce := c.r.Header.Get("Content-Encoding"); ce != "" {
+		if !compress.Supported(ce) {
+			e.reject(c, nd, http.StatusUnsupportedMediaType, fmt.Sprintf("content encoding %s not support", ce))
+			return
+		}
+		body, err := compress.NewReader(ce, c.r.Body)
+		if err != nil {
+			e.reject(c, nd, http.StatusBadRequest, fmt.Sprintf("request body decode error:%v", err.Error()))
+			return
 		}
-		c.cores = current.cores
+		if limit > 0 {
+			body = http.MaxBytesReader(c.rw.ResponseWriter, body, limit)
+		}
+		c.r.Body = body
+		c.r.Header.Del("Content-Encoding")
+		c.r.ContentLength = -1
+	}
+	// 流式路由由handler自行读取body
+	if nd.opts.stream {
+		c.do()
+		return
 	}
 	// 提前解析body
 	data, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		_ = c.HttpError(499, fmt.Sprintf("request body decode error:%v", err.Error()))
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			e.reject(c, nd, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		e.reject(c, nd, 499, fmt.Sprintf("request body decode error:%v", err.Error()))
 		return
 	}
 	// Reset resp.Body so it can be use again
@@ -173,7 +282,7 @@ func (e *Engine) handleHTTPRequest(c *Context) {
 			var s string
 			s, err = e.crypto.Decrypt(string(data))
 			if err != nil {
-				_ = c.HttpError(499, fmt.Sprintf("request body decrypt error:%v", err.Error()))
+				e.reject(c, nd, 499, fmt.Sprintf("request body decrypt error:%v", err.Error()))
 				return
 			}
 			data = []byte(s)
@@ -183,13 +292,61 @@ func (e *Engine) handleHTTPRequest(c *Context) {
 	c.do()
 }
 
+// reject 路由匹配后 执行handler前拒绝请求 同样执行分组中间件 使指标/日志等中间件可以记录
+func (e *Engine) reject(c *Context, nd *node, code int, message string) {
+	mws := nd.opts.mws
+	c.cores = append(nd.cores[:mws:mws], func(c *Context) {
+		_ = c.HttpError(code, message)
+	})
+	c.do()
+}
+
+// handleOptions OPTIONS自动应答 只执行路由所在分组的中间件(例如CORS)
+func (e *Engine) handleOptions(c *Context, allows []string) {
+	methods := allows
+	if m := c.r.Header.Get("Access-Control-Request-Method"); m != "" {
+		methods = append([]string{m}, allows...)
+	}
+	var nd *node
+	for _, m := range methods {
+		if m == http.MethodOptions {
+			continue
+		}
+		c.params = c.params[:0]
+		if nd = e.tree.getRoute(m, c.r.URL.Path, &c.params); nd != nil {
+			break
+		}
+	}
+	var cores []core
+	if nd != nil {
+		c.fullPath = nd.fullPath
+		cores = append(cores, nd.cores[:nd.opts.mws]...)
+	}
+	c.cores = append(cores, func(c *Context) {
+		c.w.Header().Set("Allow", strings.Join(allows, ", "))
+		c.w.WriteHeader(http.StatusNoContent)
+	})
+	c.do()
+}
+
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c := e.pool.Get().(*Context)
-	c.w = w
+	// 记录状态码和响应大小
+	c.rw.reset(w)
+	c.w = &c.rw
 	c.r = req
 	c.reset()
+	c.srv = e
 
 	e.handleHTTPRequest(c)
+	// 关闭流式响应 防止handler返回后继续写入
+	if c.stream != nil {
+		c.stream.close()
+	}
+	// 删除multipart临时文件
+	if c.form != nil {
+		_ = c.form.RemoveAll()
+	}
 
 	e.pool.Put(c)
 }
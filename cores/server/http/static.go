@@ -0,0 +1,173 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+///////////////////////////////////////////
+// 静态文件
+///////////////////////////////////////////
+
+type StaticOption func(*staticOptions)
+
+type staticOptions struct {
+	index  string
+	spa    bool
+	maxAge time.Duration
+}
+
+// StaticIndex 目录默认文件 默认index.html
+func StaticIndex(name string) StaticOption {
+	return func(o *staticOptions) {
+		o.index = name
+	}
+}
+
+// StaticSPA 单页应用模式 不存在且无扩展名的路径返回根目录index文件
+func StaticSPA() StaticOption {
+	return func(o *staticOptions) {
+		o.spa = true
+	}
+}
+
+// StaticMaxAge Cache-Control max-age
+func StaticMaxAge(d time.Duration) StaticOption {
+	return func(o *staticOptions) {
+		o.maxAge = d
+	}
+}
+
+// Static 注册本地目录静态文件
+func (g *Group) Static(prefix string, dir string, opts ...StaticOption) *Group {
+	return g.StaticFS(prefix, os.DirFS(dir), opts...)
+}
+
+// StaticFS 注册fs.FS静态文件 支持embed.FS
+func (g *Group) StaticFS(prefix string, fsys fs.FS, opts ...StaticOption) *Group {
+	o := &staticOptions{
+		index: "index.html",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	s := &staticServer{
+		fsys: fsys,
+		opts: o,
+	}
+	return g.GET(joinPaths(prefix, "/*filepath"), s.serve)
+}
+
+// 预压缩文件 按优先级排列
+var _precompressed = []struct {
+	encoding string
+	ext      string
+}{
+	{encoding: "br", ext: ".br"},
+	{encoding: "gzip", ext: ".gz"},
+}
+
+type staticServer struct {
+	fsys  fs.FS
+	opts  *staticOptions
+	etags sync.Map
+}
+
+func (s *staticServer) serve(c *Context) {
+	name := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+	if name == "" {
+		name = "."
+	}
+	reqName := name
+	info, err := fs.Stat(s.fsys, name)
+	if err == nil && info.IsDir() {
+		name = path.Join(name, s.opts.index)
+		info, err = fs.Stat(s.fsys, name)
+	}
+	if err != nil || info.IsDir() {
+		// 单页应用仅对无扩展名的路径回退 静态资源缺失仍返回404
+		if !s.opts.spa || path.Ext(reqName) != "" {
+			_ = c.HttpError(http.StatusNotFound, "page not found")
+			return
+		}
+		name = s.opts.index
+		if info, err = fs.Stat(s.fsys, name); err != nil || info.IsDir() {
+			_ = c.HttpError(http.StatusNotFound, "page not found")
+			return
+		}
+	}
+	h := c.Writer().Header()
+	if s.opts.maxAge > 0 {
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.opts.maxAge/time.Second)))
+	}
+	// 优先使用预压缩文件
+	file := name
+	accept := c.Request().Header.Get("Accept-Encoding")
+	for _, pc := range _precompressed {
+		if !strings.Contains(accept, pc.encoding) {
+			continue
+		}
+		if pi, e := fs.Stat(s.fsys, name+pc.ext); e == nil && !pi.IsDir() {
+			file = name + pc.ext
+			info = pi
+			h.Set("Content-Encoding", pc.encoding)
+			break
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+	f, err := s.fsys.Open(file)
+	if err != nil {
+		_ = c.HttpError(http.StatusInternalServerError, fmt.Sprintf("open file error:%v", err))
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		bs, e := io.ReadAll(f)
+		if e != nil {
+			_ = c.HttpError(http.StatusInternalServerError, fmt.Sprintf("read file error:%v", e))
+			return
+		}
+		content = bytes.NewReader(bs)
+	}
+	etag, err := s.etag(file, info, content)
+	if err != nil {
+		_ = c.HttpError(http.StatusInternalServerError, fmt.Sprintf("read file error:%v", err))
+		return
+	}
+	h.Set("ETag", etag)
+	// ServeContent处理Range/If-None-Match/If-Modified-Since 使用原文件名推断Content-Type
+	http.ServeContent(c.Writer(), c.Request(), path.Base(name), info.ModTime(), content)
+}
+
+// etag 有修改时间时使用大小和修改时间 embed.FS等无修改时间时使用内容摘要
+func (s *staticServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano()), nil
+	}
+	if v, ok := s.etags.Load(name); ok {
+		return v.(string), nil
+	}
+	hash := sha1.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
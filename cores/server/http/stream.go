@@ -0,0 +1,220 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrStreamClosed = errors.New("http stream closed")
+
+// ErrInvalidSSEField SSE事件的id/name包含换行 会注入额外的字段或事件
+var ErrInvalidSSEField = errors.New("sse id and event name must not contain CR or LF")
+
+///////////////////////////////////////////
+// 流式响应
+// handler返回后engine自动关闭stream 关闭后写入返回ErrStreamClosed
+///////////////////////////////////////////
+
+type stream struct {
+	r       *http.Request
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closeCh chan struct{}
+	mu      sync.Mutex
+	start   time.Time
+	events  int
+	closed  bool
+}
+
+func newStream(c *Context, contentType string) (*stream, error) {
+	if c.stream != nil {
+		return nil, errors.New("http stream already started")
+	}
+	if _, ok := c.rw.ResponseWriter.(http.Flusher); !ok {
+		return nil, errors.New("http response writer not support flush")
+	}
+	flusher, ok := c.w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("http response writer not support flush")
+	}
+	h := c.w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// 关闭nginx缓冲
+	h.Set("X-Accel-Buffering", "no")
+	c.w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &stream{
+		r:       c.r,
+		w:       c.w,
+		flusher: flusher,
+		closeCh: make(chan struct{}),
+		start:   time.Now(),
+	}
+	c.stream = s
+	return s, nil
+}
+
+// write 写入并flush count为true时计入事件数
+func (s *stream) write(bs []byte, count bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	// 客户端断开
+	if err := s.r.Context().Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(bs); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	if count {
+		s.events++
+	}
+	return nil
+}
+
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.closeCh)
+	}
+}
+
+// Done 客户端断开时关闭
+func (s *stream) Done() <-chan struct{} {
+	return s.r.Context().Done()
+}
+
+func (s *stream) stat() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]interface{}{
+		"events":   s.events,
+		"duration": time.Since(s.start).Milliseconds(),
+	}
+}
+
+type SSEStream struct {
+	*stream
+}
+
+// SSE 开启Server-Sent Events响应
+func (c *Context) SSE() (*SSEStream, error) {
+	s, err := newStream(c, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	return &SSEStream{stream: s}, nil
+}
+
+// Event 发送事件 id和name为空时不写入 包含换行时返回ErrInvalidSSEField
+// data为string/[]byte时原样写入 其他类型json序列化 多行data按CRLF/CR/LF拆分为多个data字段
+func (s *SSEStream) Event(id string, name string, data interface{}) error {
+	if strings.ContainsAny(id, "\r\n") || strings.ContainsAny(name, "\r\n") {
+		return ErrInvalidSSEField
+	}
+	var payload string
+	switch d := data.(type) {
+	case string:
+		payload = d
+	case []byte:
+		payload = string(d)
+	default:
+		bs, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		payload = string(bs)
+	}
+	var buf bytes.Buffer
+	if id != "" {
+		buf.WriteString("id: " + id + "\n")
+	}
+	if name != "" {
+		buf.WriteString("event: " + name + "\n")
+	}
+	for _, line := range sseLines(payload) {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes(), true)
+}
+
+// Comment 发送注释 客户端忽略 可用于保活 多行时每行作为一条注释
+func (s *SSEStream) Comment(comment string) error {
+	var buf bytes.Buffer
+	for _, line := range sseLines(comment) {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes(), false)
+}
+
+// sseLines 按SSE规范的行结束符CRLF/CR/LF拆分
+func sseLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// Retry 设置客户端重连间隔
+func (s *SSEStream) Retry(d time.Duration) error {
+	return s.write([]byte("retry: "+strconv.FormatInt(d.Milliseconds(), 10)+"\n\n"), false)
+}
+
+// Heartbeat 定时发送心跳注释 客户端断开或stream关闭后停止
+func (s *SSEStream) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.Done():
+				return
+			case <-s.closeCh:
+				return
+			case <-ticker.C:
+				if err := s.Comment("heartbeat"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+type LineStream struct {
+	*stream
+}
+
+// JsonLines 开启分块传输的json lines(ndjson)响应
+func (c *Context) JsonLines() (*LineStream, error) {
+	s, err := newStream(c, "application/x-ndjson")
+	if err != nil {
+		return nil, err
+	}
+	return &LineStream{stream: s}, nil
+}
+
+// Write 写入一行json
+func (s *LineStream) Write(v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write(append(bs, '\n'), true)
+}
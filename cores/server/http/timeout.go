@@ -0,0 +1,176 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+)
+
+///////////////////////////////////////////
+// 超时中间件
+// 到期后立即返回超时响应 handler继续执行至返回 期间的写入被丢弃并返回http.ErrHandlerTimeout
+// handler应通过Ctx()感知超时 http/rpc客户端和gorm使用该context时同样受deadline约束
+///////////////////////////////////////////
+
+type TimeoutOption func(*timeoutOptions)
+
+type timeoutOptions struct {
+	status int
+	err    *gErrors.Error
+}
+
+// TimeoutStatus 超时响应的http状态码 默认503
+func TimeoutStatus(code int) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.status = code
+	}
+}
+
+// TimeoutError 超时响应body 使用engine的响应渲染以json返回 默认"request timeout"
+func TimeoutError(err *gErrors.Error) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.err = err
+	}
+}
+
+func Timeout(d time.Duration, opts ...TimeoutOption) core {
+	o := &timeoutOptions{
+		status: http.StatusServiceUnavailable,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.err == nil {
+		o.err = gErrors.New(o.status, "request timeout")
+	}
+
+	return func(c *Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.ctx, d)
+		defer cancel()
+		c.ctx = ctx
+
+		// 预先渲染超时响应 超时协程不访问Context
+		rp := c.renderer().Err(ctx, o.err)
+		contentType := "application/json"
+		if rp.Problem {
+			contentType = "application/problem+json"
+		}
+		body, _ := json.Marshal(rp.Body)
+
+		tw := &timeoutWriter{
+			w: c.w,
+			h: make(http.Header),
+		}
+		c.w = tw
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					tw.timeout(o.status, contentType, body)
+				}
+			}
+		}()
+		defer func() {
+			close(done)
+			// 等待超时响应写完 后续中间件读取的状态码和大小保持一致
+			tw.mu.Lock()
+			tw.finished = true
+			replied := tw.replied
+			tw.mu.Unlock()
+			c.w = tw.w
+			if replied {
+				c.buildRespData(o.status, rp.Body)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// timeoutWriter handler写入前header保存在独立map中 避免与超时响应并发修改
+type timeoutWriter struct {
+	w        http.ResponseWriter
+	h        http.Header
+	mu       sync.Mutex
+	wrote    bool
+	timedOut bool
+	replied  bool
+	finished bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.wrote {
+		return
+	}
+	tw.wrote = true
+	dst := tw.w.Header()
+	for k, vs := range tw.h {
+		dst[k] = vs
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+func (tw *timeoutWriter) Write(bs []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(bs)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timeout 已开始写入的响应无法替换 仅丢弃后续写入
+func (tw *timeoutWriter) timeout(status int, contentType string, body []byte) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.finished {
+		return
+	}
+	tw.timedOut = true
+	if tw.wrote {
+		return
+	}
+	tw.wrote = true
+	tw.replied = true
+	tw.w.Header().Set("Content-Type", contentType)
+	tw.w.WriteHeader(status)
+	_, _ = tw.w.Write(body)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -1,60 +1,222 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
+type nodeType uint8
+
+const (
+	staticNode nodeType = iota
+	rootNode
+	paramNode
+	catchAllNode
+)
+
+// param 路由参数
+type param struct {
+	key   string
+	value string
+}
+
+type params []param
+
+func (ps params) get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.key == name {
+			return p.value, true
+		}
+	}
+	return "", false
+}
+
 type node struct {
 	path     string
+	fullPath string
+	nType    nodeType
 	children map[string]*node
-	isRoot   bool
-	cores    []core
+	// 参数节点 /:name
+	paramChild *node
+	// 通配节点 /*name
+	wildChild *node
+	cores     []core
+	opts      routeOptions
+}
+
+// routeOptions 路由配置
+type routeOptions struct {
+	// cores中分组中间件的数量 OPTIONS自动应答时只执行中间件
+	mws int
+	// body大小限制 0:使用engine配置 <0:不限制
+	maxBodySize int64
+	// 流式路由 不提前读取和解密body
+	stream bool
+	// 接口文档 不继承到子分组
+	doc *routeDoc
+}
+
+func newNode(path string, nType nodeType) *node {
+	return &node{
+		path:     path,
+		nType:    nType,
+		children: make(map[string]*node),
+	}
+}
+
+// name 参数节点/通配节点的参数名
+func (n *node) name() string {
+	return n.path[1:]
 }
 
 type trees map[string]*node
 
 func newTree() trees {
 	return map[string]*node{
-		http.MethodPost:   nil,
-		http.MethodGet:    nil,
-		http.MethodDelete: nil,
-		http.MethodPatch:  nil,
-		http.MethodPut:    nil,
+		http.MethodPost:    nil,
+		http.MethodGet:     nil,
+		http.MethodDelete:  nil,
+		http.MethodPatch:   nil,
+		http.MethodPut:     nil,
+		http.MethodHead:    nil,
+		http.MethodOptions: nil,
+	}
+}
+
+func splitPath(path string) []string {
+	ss := strings.Split(path, "/")
+	var ns []string
+	for _, s := range ss {
+		if s != "" {
+			ns = append(ns, s)
+		}
 	}
+	return ns
 }
 
-func (t trees) addRoute(method string, path string, cores ...core) {
+func (t trees) addRoute(method string, path string, opts routeOptions, cores ...core) {
 	if len(path) == 0 {
 		panic("path length is zero")
 	}
+	if path[0] != '/' {
+		panic(fmt.Sprintf("http router path:%s must begin with '/'", path))
+	}
 	if t[method] == nil {
-		t[method] = &node{
-			isRoot:   true,
-			children: make(map[string]*node),
-		}
-	}
-	if len(path) == 1 && path[0] == '/' {
-		t[method].cores = append(t[method].cores, cores...)
-	} else {
-		ss := strings.Split(path, "/")
-		var ns []string
-		for _, s := range ss {
-			if s != "" {
-				ns = append(ns, s)
+		t[method] = newNode("/", rootNode)
+	}
+	current := t[method]
+	ns := splitPath(path)
+	for idx, s := range ns {
+		switch s[0] {
+		case ':':
+			if len(s) == 1 {
+				panic(fmt.Sprintf("http router path:%s param name is empty", path))
 			}
-		}
-		current := t[method]
-		for _, s := range ns {
+			if current.paramChild == nil {
+				current.paramChild = newNode(s, paramNode)
+			} else if current.paramChild.path != s {
+				panic(fmt.Sprintf("http router path:%s param %s conflicts with existing %s",
+					path, s, current.paramChild.path))
+			}
+			current = current.paramChild
+		case '*':
+			if len(s) == 1 {
+				panic(fmt.Sprintf("http router path:%s catch-all name is empty", path))
+			}
+			if idx != len(ns)-1 {
+				panic(fmt.Sprintf("http router path:%s catch-all must be the last segment", path))
+			}
+			if current.wildChild == nil {
+				current.wildChild = newNode(s, catchAllNode)
+			} else if current.wildChild.path != s {
+				panic(fmt.Sprintf("http router path:%s catch-all %s conflicts with existing %s",
+					path, s, current.wildChild.path))
+			}
+			current = current.wildChild
+		default:
 			if _, has := current.children[s]; !has {
-				current.children[s] = &node{
-					path:     s,
-					isRoot:   false,
-					children: make(map[string]*node),
-				}
+				current.children[s] = newNode(s, staticNode)
 			}
 			current = current.children[s]
 		}
-		current.cores = append(current.cores, cores...)
 	}
+	if len(current.cores) > 0 {
+		panic(fmt.Sprintf("http router %s %s conflicts with existing %s", method, path, current.fullPath))
+	}
+	current.cores = cores
+	current.opts = opts
+	current.fullPath = path
+}
+
+// getRoute 路由匹配 优先级: 静态节点 > 参数节点 > 通配节点
+func (t trees) getRoute(method string, path string, ps *params) *node {
+	root := t[method]
+	if root == nil {
+		return nil
+	}
+	return root.find(splitPath(path), ps)
+}
+
+// allowed 路径支持的请求方法 GET自动支持HEAD 所有路径均支持OPTIONS
+func (t trees) allowed(path string) []string {
+	var (
+		allows []string
+		ps     params
+	)
+	ns := splitPath(path)
+	for method, root := range t {
+		if root == nil {
+			continue
+		}
+		ps = ps[:0]
+		if root.find(ns, &ps) != nil {
+			allows = append(allows, method)
+		}
+	}
+	if len(allows) == 0 {
+		return nil
+	}
+	if contains(allows, http.MethodGet) && !contains(allows, http.MethodHead) {
+		allows = append(allows, http.MethodHead)
+	}
+	if !contains(allows, http.MethodOptions) {
+		allows = append(allows, http.MethodOptions)
+	}
+	sort.Strings(allows)
+	return allows
+}
+
+func (n *node) find(ns []string, ps *params) *node {
+	if len(ns) == 0 {
+		if len(n.cores) > 0 {
+			return n
+		}
+		// 通配节点可以匹配空路径 例如/static/*filepath匹配/static/
+		if n.wildChild != nil && len(n.wildChild.cores) > 0 {
+			*ps = append(*ps, param{key: n.wildChild.name(), value: ""})
+			return n.wildChild
+		}
+		return nil
+	}
+	s := ns[0]
+	if child, has := n.children[s]; has {
+		if nd := child.find(ns[1:], ps); nd != nil {
+			return nd
+		}
+	}
+	if n.paramChild != nil {
+		l := len(*ps)
+		*ps = append(*ps, param{key: n.paramChild.name(), value: s})
+		if nd := n.paramChild.find(ns[1:], ps); nd != nil {
+			return nd
+		}
+		*ps = (*ps)[:l]
+	}
+	if n.wildChild != nil && len(n.wildChild.cores) > 0 {
+		*ps = append(*ps, param{key: n.wildChild.name(), value: strings.Join(ns, "/")})
+		return n.wildChild
+	}
+	return nil
 }
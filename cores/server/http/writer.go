@@ -0,0 +1,57 @@
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// responseWriter 记录响应状态码和body大小
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) reset(rw http.ResponseWriter) {
+	w.ResponseWriter = rw
+	w.status = 0
+	w.size = 0
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(bs []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(bs)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http response writer not support hijack")
+	}
+	return h.Hijack()
+}
+
+// Unwrap 供http.ResponseController获取原始ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,287 @@
+package render
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+	"github.com/curry-mz/sagittarius-golang/cores/logger"
+)
+
+///////////////////////////////////////////
+// 响应渲染 http与socket.io共用
+///////////////////////////////////////////
+
+// Response 渲染结果
+type Response struct {
+	// http状态码 socket.io忽略
+	HttpCode int
+	// RFC 7807 Content-Type使用application/problem+json(xml)
+	Problem bool
+	// 响应体
+	Body interface{}
+}
+
+// ResponseRenderer 响应渲染接口 实现接口即可自定义响应格式
+type ResponseRenderer interface {
+	OK(ctx context.Context, data interface{}) *Response
+	Err(ctx context.Context, err *gErrors.Error) *Response
+}
+
+// Envelope 响应体 支持json/form/xml编码
+type Envelope map[string]interface{}
+
+// MarshalXML map按key排序编码为<response><key>value</key></response>
+// 切片编码为<details><detail>...</detail></details> 元素名为key去掉末尾s 否则为item
+func (e Envelope) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "response"}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if e[k] == nil {
+			continue
+		}
+		el := xml.StartElement{Name: xml.Name{Local: k}}
+		rv := reflect.ValueOf(e[k])
+		if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+			if err := enc.EncodeElement(e[k], el); err != nil {
+				return err
+			}
+			continue
+		}
+		item := xml.StartElement{Name: xml.Name{Local: "item"}}
+		if strings.HasSuffix(k, "s") && len(k) > 1 {
+			item.Name.Local = strings.TrimSuffix(k, "s")
+		}
+		if err := enc.EncodeToken(el); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.EncodeElement(rv.Index(i).Interface(), item); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(el.End()); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+type Option func(*options)
+
+type extra struct {
+	field string
+	value func(ctx context.Context) interface{}
+}
+
+type options struct {
+	statusField    string
+	messageField   string
+	dataField      string
+	detailsField   string
+	successMessage string
+	statusMapper   func(code int) int
+	problemType    func(code int) string
+	extras         []extra
+}
+
+// StatusField 业务码字段名 默认status
+func StatusField(name string) Option {
+	return func(o *options) {
+		o.statusField = name
+	}
+}
+
+// MessageField 信息字段名 默认message
+func MessageField(name string) Option {
+	return func(o *options) {
+		o.messageField = name
+	}
+}
+
+// DataField 数据字段名 默认data
+func DataField(name string) Option {
+	return func(o *options) {
+		o.dataField = name
+	}
+}
+
+// DetailsField 字段错误详情字段名 默认details
+func DetailsField(name string) Option {
+	return func(o *options) {
+		o.detailsField = name
+	}
+}
+
+// SuccessMessage 成功信息 默认success
+func SuccessMessage(message string) Option {
+	return func(o *options) {
+		o.successMessage = message
+	}
+}
+
+// StatusMapper 错误码映射http状态码
+func StatusMapper(f func(code int) int) Option {
+	return func(o *options) {
+		o.statusMapper = f
+	}
+}
+
+// ProblemType RFC 7807 type字段 默认about:blank
+func ProblemType(f func(code int) string) Option {
+	return func(o *options) {
+		o.problemType = f
+	}
+}
+
+// Extra 追加字段
+func Extra(field string, f func(ctx context.Context) interface{}) Option {
+	return func(o *options) {
+		o.extras = append(o.extras, extra{field: field, value: f})
+	}
+}
+
+// TraceID 追加链路id字段 未开启链路追踪时不写入
+func TraceID(field string) Option {
+	return Extra(field, func(ctx context.Context) interface{} {
+		if id := logger.TraceID(ctx); id != "" {
+			return id
+		}
+		return nil
+	})
+}
+
+// ServerTime 追加服务器时间字段(毫秒时间戳)
+func ServerTime(field string) Option {
+	return Extra(field, func(ctx context.Context) interface{} {
+		return time.Now().UnixMilli()
+	})
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		statusField:    "status",
+		messageField:   "message",
+		dataField:      "data",
+		detailsField:   "details",
+		successMessage: "success",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) withExtras(ctx context.Context, body Envelope) Envelope {
+	for _, e := range o.extras {
+		if v := e.value(ctx); v != nil {
+			body[e.field] = v
+		}
+	}
+	return body
+}
+
+///////////////////////////////////////////
+// 默认响应格式 {"status","message","data"} 错误也使用http 200
+///////////////////////////////////////////
+
+type envelopeRenderer struct {
+	opts *options
+}
+
+func NewEnvelope(opts ...Option) ResponseRenderer {
+	return &envelopeRenderer{opts: newOptions(opts...)}
+}
+
+func (r *envelopeRenderer) OK(ctx context.Context, data interface{}) *Response {
+	body := Envelope{
+		r.opts.statusField:  0,
+		r.opts.messageField: r.opts.successMessage,
+	}
+	if data != nil {
+		body[r.opts.dataField] = data
+	}
+	return &Response{
+		HttpCode: http.StatusOK,
+		Body:     r.opts.withExtras(ctx, body),
+	}
+}
+
+func (r *envelopeRenderer) Err(ctx context.Context, err *gErrors.Error) *Response {
+	body := Envelope{
+		r.opts.statusField:  err.Code(),
+		r.opts.messageField: err.Message(),
+	}
+	if len(err.Details()) > 0 {
+		body[r.opts.detailsField] = err.Details()
+	}
+	httpCode := http.StatusOK
+	if r.opts.statusMapper != nil {
+		httpCode = r.opts.statusMapper(err.Code())
+	}
+	return &Response{
+		HttpCode: httpCode,
+		Body:     r.opts.withExtras(ctx, body),
+	}
+}
+
+///////////////////////////////////////////
+// RFC 7807 problem details
+// 成功时直接返回data 错误码在400-599之间时作为http状态码 否则为400
+///////////////////////////////////////////
+
+type problemRenderer struct {
+	opts *options
+}
+
+func NewProblem(opts ...Option) ResponseRenderer {
+	return &problemRenderer{opts: newOptions(opts...)}
+}
+
+func (r *problemRenderer) OK(ctx context.Context, data interface{}) *Response {
+	return &Response{
+		HttpCode: http.StatusOK,
+		Body:     data,
+	}
+}
+
+func (r *problemRenderer) Err(ctx context.Context, err *gErrors.Error) *Response {
+	httpCode := http.StatusBadRequest
+	if err.Code() >= 400 && err.Code() < 600 {
+		httpCode = err.Code()
+	}
+	if r.opts.statusMapper != nil {
+		httpCode = r.opts.statusMapper(err.Code())
+	}
+	typ := "about:blank"
+	if r.opts.problemType != nil {
+		typ = r.opts.problemType(err.Code())
+	}
+	body := Envelope{
+		"type":   typ,
+		"title":  http.StatusText(httpCode),
+		"status": httpCode,
+		"detail": err.Message(),
+		"code":   err.Code(),
+	}
+	if len(err.Details()) > 0 {
+		body["errors"] = err.Details()
+	}
+	return &Response{
+		HttpCode: httpCode,
+		Problem:  true,
+		Body:     r.opts.withExtras(ctx, body),
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	gCtx "github.com/curry-mz/sagittarius-golang/context"
@@ -16,77 +17,146 @@ import (
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 ///////////////////////////////////////////
 // 服务端拦截器
 ///////////////////////////////////////////
 
+// RecoverServerInterceptor panic时记录日志并上报sentry 返回codes.Internal
 func RecoverServerInterceptor(lgr *logger.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		defer func() {
-			var rerr interface{}
-			if rerr = recover(); rerr != nil {
-				var buf [1 << 10]byte
-				runtime.Stack(buf[:], true)
-				lgr.Error(ctx, "grpc error, server:%v, method:%s, message:%v\n, stack:%s", info.Server, info.FullMethod, rerr, string(buf[:]))
-
-				hub := sentry.CurrentHub().Clone()
-				hub.CaptureException(errors.New(string(buf[:])))
-				hub.Flush(5 * time.Second)
+			if rerr := recover(); rerr != nil {
+				reportPanic(ctx, lgr, info.Server, info.FullMethod, rerr)
+				resp, err = nil, status.Errorf(codes.Internal, "panic: %v", rerr)
 			}
 		}()
 		return handler(ctx, req)
 	}
 }
 
+// RecoverServerStreamInterceptor panic时记录日志并上报sentry 返回codes.Internal结束流
+func RecoverServerStreamInterceptor(lgr *logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if rerr := recover(); rerr != nil {
+				reportPanic(ss.Context(), lgr, srv, info.FullMethod, rerr)
+				err = status.Errorf(codes.Internal, "panic: %v", rerr)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func reportPanic(ctx context.Context, lgr *logger.Logger, server interface{}, method string, rerr interface{}) {
+	var buf [1 << 10]byte
+	runtime.Stack(buf[:], true)
+	lgr.Error(ctx, "grpc error, server:%v, method:%s, message:%v\n, stack:%s", server, method, rerr, string(buf[:]))
+
+	hub := sentry.CurrentHub().Clone()
+	hub.CaptureException(errors.New(string(buf[:])))
+	hub.Flush(5 * time.Second)
+}
+
+// RequestIDServerUnaryInterceptor 读取metadata中的x-request-id 不存在或不合法时生成
+func RequestIDServerUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		return handler(requestIDContext(ctx), req)
+	}
+}
+
+// RequestIDServerStreamInterceptor 同RequestIDServerUnaryInterceptor
+func RequestIDServerStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: requestIDContext(ss.Context())})
+	}
+}
+
+func requestIDContext(ctx context.Context) context.Context {
+	rpcMD, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		rpcMD = metadata.New(nil)
+	}
+	rid := gCtx.GetRequestIDMeta(gCtx.Metadata{MD: rpcMD})
+	if !gCtx.ValidRequestID(rid) {
+		rid = gCtx.NewRequestID()
+	}
+	ctx = gCtx.NewRequestIDContext(ctx, rid)
+	// 响应header回传请求id
+	md := gCtx.Metadata{MD: metadata.New(nil)}
+	gCtx.SetRequestIDMeta(md, rid)
+	_ = grpc.SetHeader(ctx, md.MD)
+	return ctx
+}
+
 func TracingServerUnaryInterceptor(tracer opentracing.Tracer) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		rpcMD, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			rpcMD = metadata.New(nil)
-		}
-		md := gCtx.Metadata{MD: rpcMD}
-		spanContext, err := tracer.Extract(
-			opentracing.TextMap,
-			md,
-		)
-		var opts []opentracing.StartSpanOption
-		if err != nil && err != opentracing.ErrSpanContextNotFound {
-			opts = append(opts, opentracing.Tag{Key: string(ext.Component), Value: "gRPC Server"},
-				ext.SpanKindRPCServer)
-		} else {
-			opts = append(opts, ext.RPCServerOption(spanContext),
-				opentracing.Tag{Key: string(ext.Component), Value: "gRPC Server"},
-				ext.SpanKindRPCServer)
-		}
-		span := tracer.StartSpan(
-			info.FullMethod,
-			opts...,
-		)
+		ctx, span := startServerSpan(ctx, tracer, info.FullMethod)
 		defer span.Finish()
+		return handler(ctx, req)
+	}
+}
 
-		ctx = opentracing.ContextWithSpan(ctx, span)
-		// context 写入上游服务信息
-		// 获取远端ip
-		var peerIP string
-		p, ok := peer.FromContext(ctx)
-		if ok {
-			peerIP = p.Addr.String()
-			peerIP = strings.Split(peerIP, ":")[0]
-		}
-		sk := gCtx.GetUberMeta(md)
+// TracingServerStreamInterceptor span覆盖整个流 同时写入上游服务信息
+func TracingServerStreamInterceptor(tracer opentracing.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startServerSpan(ss.Context(), tracer, info.FullMethod)
+		defer span.Finish()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func startServerSpan(ctx context.Context, tracer opentracing.Tracer, method string) (context.Context, opentracing.Span) {
+	rpcMD, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		rpcMD = metadata.New(nil)
+	}
+	md := gCtx.Metadata{MD: rpcMD}
+	spanContext, err := tracer.Extract(
+		opentracing.TextMap,
+		md,
+	)
+	var opts []opentracing.StartSpanOption
+	if err != nil && err != opentracing.ErrSpanContextNotFound {
+		opts = append(opts, opentracing.Tag{Key: string(ext.Component), Value: "gRPC Server"},
+			ext.SpanKindRPCServer)
+	} else {
+		opts = append(opts, ext.RPCServerOption(spanContext),
+			opentracing.Tag{Key: string(ext.Component), Value: "gRPC Server"},
+			ext.SpanKindRPCServer)
+	}
+	span := tracer.StartSpan(
+		method,
+		opts...,
+	)
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	// context 写入上游服务信息
+	// 获取远端ip
+	var peerIP string
+	p, ok := peer.FromContext(ctx)
+	if ok {
+		peerIP = p.Addr.String()
+		peerIP = strings.Split(peerIP, ":")[0]
+	}
+	// 非本框架的调用方没有服务信息
+	if sk := gCtx.GetUberMeta(md); sk != "" {
 		ss := strings.Split(sk, ".")
-		ctx = gCtx.NewClientContext(ctx, gCtx.TransData{
-			Endpoint:    peerIP,
-			Namespace:   ss[0],
-			Product:     ss[1],
-			ServiceName: strings.Join(ss[2:], "."),
-		})
-		return handler(ctx, req)
+		if len(ss) >= 2 {
+			ctx = gCtx.NewClientContext(ctx, gCtx.TransData{
+				Endpoint:    peerIP,
+				Namespace:   ss[0],
+				Product:     ss[1],
+				ServiceName: strings.Join(ss[2:], "."),
+			})
+		}
 	}
+	return ctx, span
 }
 
 func AccessServerUnaryInterceptor(lgr *logger.Logger, requestEnable bool) grpc.UnaryServerInterceptor {
@@ -101,9 +171,10 @@ func AccessServerUnaryInterceptor(lgr *logger.Logger, requestEnable bool) grpc.U
 
 		defer func() {
 			logData := map[string]interface{}{
-				"Peer":   td,
-				"Method": info.FullMethod,
-				"Cost":   fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
+				"Peer":      td,
+				"RequestID": gCtx.RequestID(ctx),
+				"Method":    info.FullMethod,
+				"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
 			}
 			if requestEnable {
 				logData["Request"] = req
@@ -123,3 +194,74 @@ func AccessServerUnaryInterceptor(lgr *logger.Logger, requestEnable bool) grpc.U
 		return handler(ctx, req)
 	}
 }
+
+// AccessServerStreamInterceptor 流结束时记录access日志 包括收发的消息数 不记录消息内容
+func AccessServerStreamInterceptor(lgr *logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := ss.Context()
+		// 获取远端服务信息
+		td, ok := gCtx.FromClientContext(ctx)
+		if !ok {
+			td = gCtx.TransData{}
+		}
+		// start时间
+		start := time.Now().UnixMilli()
+		cs := &countingStream{ServerStream: ss}
+
+		defer func() {
+			logData := map[string]interface{}{
+				"Peer":      td,
+				"RequestID": gCtx.RequestID(ctx),
+				"Method":    info.FullMethod,
+				"Recv":      atomic.LoadInt64(&cs.recv),
+				"Sent":      atomic.LoadInt64(&cs.sent),
+				"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
+			}
+			if err != nil {
+				logData["Error"] = err.Error()
+			}
+			bs, e := json.Marshal(logData)
+			if e != nil {
+				return
+			}
+			lgr.Write(ctx, "%s", string(bs))
+		}()
+		return handler(srv, cs)
+	}
+}
+
+///////////////////////////////////////////
+// 流包装 grpc.ServerStream的context不可修改 通过包装替换
+///////////////////////////////////////////
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// countingStream 统计收发的消息数
+type countingStream struct {
+	grpc.ServerStream
+	recv int64
+	sent int64
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	}
+	return err
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.recv, 1)
+	}
+	return err
+}
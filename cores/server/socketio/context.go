@@ -3,10 +3,15 @@ package socketio
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"sync/atomic"
 
 	socketio "github.com/googollee/go-socket.io"
 
+	gCtx "github.com/curry-mz/sagittarius-golang/context"
 	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+	"github.com/curry-mz/sagittarius-golang/cores/server/render"
+	"github.com/curry-mz/sagittarius-golang/cores/validator"
 )
 
 type core func(*Context)
@@ -20,17 +25,26 @@ type Context struct {
 	data  string
 	event string
 	resp  string
+	// 业务码 JsonOK/JsonErr写入
+	code string
+
+	renderer render.ResponseRenderer
+
+	// 连接级别 握手请求的X-Request-ID 不合法时为空
+	requestID string
+	seq       uint64
 }
 
-func newContext() *Context {
+func newContext(renderer render.ResponseRenderer) *Context {
 	c := &Context{
-		index: 0,
-		cores: nil,
-		conn:  nil,
-		data:  "",
-		event: "",
-		resp:  "",
-		ctx:   context.TODO(),
+		renderer: renderer,
+		index:    0,
+		cores:    nil,
+		conn:     nil,
+		data:     "",
+		event:    "",
+		resp:     "",
+		ctx:      context.TODO(),
 	}
 	return c
 }
@@ -42,10 +56,26 @@ func (c *Context) reset() *Context {
 	c.data = ""
 	c.event = ""
 	c.resp = ""
+	c.code = ""
 	c.ctx = context.TODO()
 	return c
 }
 
+// release 连接断开 清除连接级别的数据
+func (c *Context) release() *Context {
+	c.requestID = ""
+	c.seq = 0
+	return c.reset()
+}
+
+// nextRequestID 握手请求携带合法的X-Request-ID时为<id>-<序号> 便于上游关联 否则每个事件生成
+func (c *Context) nextRequestID() string {
+	if c.requestID == "" {
+		return gCtx.NewRequestID()
+	}
+	return gCtx.ChildRequestID(c.requestID, atomic.AddUint64(&c.seq, 1))
+}
+
 func (c *Context) do() {
 	for c.index < int8(len(c.cores)) {
 		c.cores[c.index](c)
@@ -74,40 +104,23 @@ func (c *Context) Next() {
 }
 
 func (c *Context) JsonBind(v interface{}) error {
-	if len(c.data) == 0 {
-		return nil
+	if len(c.data) > 0 {
+		if err := json.Unmarshal([]byte(c.data), v); err != nil {
+			return err
+		}
 	}
-	return json.Unmarshal([]byte(c.data), v)
+	return validator.Validate(v)
 }
 
 func (c *Context) JsonOK(data interface{}) error {
-	body := map[string]interface{}{
-		"status":  0,
-		"message": "success",
-	}
-	if data != nil {
-		body["data"] = data
-	}
-	bs, err := json.Marshal(body)
-	if err != nil {
-		return err
-	}
-	c.resp = string(bs)
-	return nil
+	c.code = "0"
+	return c.JsonCustom(c.renderer.OK(c.ctx, data).Body)
 }
 
 func (c *Context) JsonErr(err error) error {
 	ge := gErrors.Cause(err)
-	body := map[string]interface{}{
-		"status":  ge.Code(),
-		"message": ge.Message(),
-	}
-	bs, err := json.Marshal(body)
-	if err != nil {
-		return err
-	}
-	c.resp = string(bs)
-	return nil
+	c.code = strconv.Itoa(ge.Code())
+	return c.JsonCustom(c.renderer.Err(c.ctx, ge).Body)
 }
 
 func (c *Context) JsonCustom(v interface{}) error {
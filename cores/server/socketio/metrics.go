@@ -0,0 +1,71 @@
+package socketio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+///////////////////////////////////////////
+// prometheus指标 按namespace和事件统计
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_eventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "socketio_server",
+		Name:      "events_total",
+		Help:      "Total number of socket.io events handled.",
+	}, []string{"namespace", "event", "biz_code"})
+
+	_eventDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "socketio_server",
+		Name:      "event_duration_seconds",
+		Help:      "Histogram of socket.io event handling latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"namespace", "event"})
+
+	_eventsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "socketio_server",
+		Name:      "events_in_flight",
+		Help:      "Number of socket.io events being handled.",
+	}, []string{"namespace", "event"})
+
+	_requestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "socketio_server",
+		Name:      "request_size_bytes",
+		Help:      "Histogram of socket.io event payload size.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"namespace", "event"})
+
+	_responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "socketio_server",
+		Name:      "response_size_bytes",
+		Help:      "Histogram of socket.io ack payload size.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"namespace", "event"})
+)
+
+// MetricsHandler 事件数/耗时/并发数/请求和响应大小 指标注册到prometheus默认registry
+func MetricsHandler() core {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_eventsTotal, _eventDuration, _eventsInFlight, _requestSize, _responseSize)
+	})
+	return func(c *Context) {
+		ns := c.conn.Namespace()
+		inFlight := _eventsInFlight.WithLabelValues(ns, c.event)
+		inFlight.Inc()
+		start := time.Now()
+
+		defer func() {
+			inFlight.Dec()
+			_eventsTotal.WithLabelValues(ns, c.event, c.code).Inc()
+			_eventDuration.WithLabelValues(ns, c.event).Observe(time.Since(start).Seconds())
+			_requestSize.WithLabelValues(ns, c.event).Observe(float64(len(c.data)))
+			_responseSize.WithLabelValues(ns, c.event).Observe(float64(len(c.resp)))
+		}()
+		c.Next()
+	}
+}
@@ -33,6 +33,15 @@ func PanicHandler(lgr *logger.Logger) core {
 	}
 }
 
+// RequestIDHandler 每个事件的请求id
+// 握手请求携带合法的X-Request-ID时为<id>-<序号> 否则生成
+func RequestIDHandler() core {
+	return func(c *Context) {
+		c.ctx = gCtx.NewRequestIDContext(c.ctx, c.nextRequestID())
+		c.Next()
+	}
+}
+
 func TracingHandler(tracer opentracing.Tracer) core {
 	return func(c *Context) {
 
@@ -61,10 +70,11 @@ func LogHandler(lgr *logger.Logger, requestEnable bool) core {
 
 		defer func() {
 			logData := map[string]interface{}{
-				"Peer":   td,
-				"Method": c.conn.URL(),
-				"Event":  c.event,
-				"Cost":   fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
+				"Peer":      td,
+				"RequestID": gCtx.RequestID(c.ctx),
+				"Method":    c.conn.URL(),
+				"Event":     c.event,
+				"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
 			}
 			if requestEnable {
 				logData["Request"] = c.data
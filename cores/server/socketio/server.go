@@ -8,6 +8,9 @@ import (
 	"sync"
 	"time"
 
+	gCtx "github.com/curry-mz/sagittarius-golang/context"
+	"github.com/curry-mz/sagittarius-golang/cores/server/render"
+
 	skio "github.com/googollee/go-socket.io"
 	"github.com/googollee/go-socket.io/engineio"
 	"github.com/googollee/go-socket.io/engineio/transport"
@@ -47,6 +50,15 @@ func PingTimeout(t time.Duration) Option {
 	}
 }
 
+// Renderer 响应渲染 默认{"status","message","data"}
+func Renderer(r render.ResponseRenderer) Option {
+	return func(e *Engine) {
+		if r != nil {
+			e.renderer = r
+		}
+	}
+}
+
 func PingInterval(t time.Duration) Option {
 	return func(e *Engine) {
 		e.pingInterval = t
@@ -64,6 +76,7 @@ type Engine struct {
 	connCloseHandler core
 	pingTimeout      time.Duration
 	pingInterval     time.Duration
+	renderer         render.ResponseRenderer
 
 	pool  sync.Pool
 	cores []core
@@ -74,12 +87,13 @@ func NewServer(opts ...Option) *Engine {
 		pingTimeout:  time.Minute,
 		pingInterval: time.Second * 20,
 		path:         "/socket.io/",
+		renderer:     render.NewEnvelope(),
 	}
 	for _, opt := range opts {
 		opt(engine)
 	}
 	engine.pool.New = func() interface{} {
-		return newContext()
+		return newContext(engine.renderer)
 	}
 	engine.newSocketIO()
 	return engine
@@ -208,6 +222,9 @@ func (s *Engine) newSocketIO() {
 		s.sioSrv.OnConnect(ns, func(conn skio.Conn) error {
 			cCtx := s.pool.Get().(*Context)
 			cCtx.conn = conn
+			if rid := gCtx.GetRequestIDHttpHeader(conn.RemoteHeader()); gCtx.ValidRequestID(rid) {
+				cCtx.requestID = rid
+			}
 			conn.SetContext(cCtx)
 			log.Println("connect, id:", conn.URL(), conn.ID())
 			return nil
@@ -218,7 +235,7 @@ func (s *Engine) newSocketIO() {
 				if s.connCloseHandler != nil {
 					s.connCloseHandler(cCtx)
 				}
-				s.pool.Put(cCtx.reset())
+				s.pool.Put(cCtx.release())
 			}
 			log.Println(ns, "disconnect,disconnect, id:", conn.URL(), conn.ID())
 		})
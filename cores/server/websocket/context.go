@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/curry-mz/sagittarius-golang/cores/validator"
+
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
 )
@@ -18,6 +20,8 @@ type Context struct {
 	data   []byte
 	index  int8
 	reader func(c *Context, v interface{}) error
+	// 响应消息大小
+	size int
 }
 
 func newContext(r func(c *Context, v interface{}) error) *Context {
@@ -40,6 +44,7 @@ func (c *Context) reset() *Context {
 	c.data = nil
 	c.header = nil
 	c.index = 0
+	c.size = 0
 	c.conn = nil
 	c.cores = nil
 	c.ctx = context.TODO()
@@ -79,11 +84,20 @@ func (c *Context) Next() {
 }
 
 func (c *Context) Bind(v interface{}) error {
-	return c.reader(c, v)
+	if err := c.reader(c, v); err != nil {
+		return err
+	}
+	return validator.Validate(v)
 }
 
 func (c *Context) Write(id int32, data interface{}) error {
-	return Write(c.ctx, id, data, c.conn)
+	// 超时后的写入丢弃
+	if c.ctx.Err() == context.DeadlineExceeded {
+		return ErrHandlerTimeout
+	}
+	n, err := write(c.ctx, id, data, c.conn)
+	c.size += n
+	return err
 }
 
 func (c *Context) Header() interface{} {
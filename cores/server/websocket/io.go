@@ -37,10 +37,16 @@ func Read(ctx context.Context, conn *Conn) (*Context, error) {
 }
 
 func Write(ctx context.Context, id int32, data interface{}, conn *Conn) error {
+	_, err := write(ctx, id, data, conn)
+	return err
+}
+
+// write 返回写入的消息大小
+func write(ctx context.Context, id int32, data interface{}, conn *Conn) (int, error) {
 	msg, err := conn.server.coder.Write(ctx, id, data)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	conn.msgCh <- msg
-	return nil
+	return len(msg), nil
 }
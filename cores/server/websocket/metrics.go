@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+///////////////////////////////////////////
+// prometheus指标 按消息id统计
+// 需通过Use挂载 Default处理的未注册消息不统计
+///////////////////////////////////////////
+
+var (
+	_metricsOnce sync.Once
+
+	_messagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "websocket_server",
+		Name:      "messages_total",
+		Help:      "Total number of websocket messages handled.",
+	}, []string{"msg_id"})
+
+	_messageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "websocket_server",
+		Name:      "message_duration_seconds",
+		Help:      "Histogram of websocket message handling latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"msg_id"})
+
+	_messagesInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "websocket_server",
+		Name:      "messages_in_flight",
+		Help:      "Number of websocket messages being handled.",
+	}, []string{"msg_id"})
+
+	_requestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "websocket_server",
+		Name:      "request_size_bytes",
+		Help:      "Histogram of websocket request message size.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"msg_id"})
+
+	_responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "websocket_server",
+		Name:      "response_size_bytes",
+		Help:      "Histogram of websocket response message size.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"msg_id"})
+)
+
+// MetricsHandler 消息数/耗时/并发数/请求和响应大小 指标注册到prometheus默认registry
+func MetricsHandler() core {
+	_metricsOnce.Do(func() {
+		prometheus.MustRegister(_messagesTotal, _messageDuration, _messagesInFlight, _requestSize, _responseSize)
+	})
+	return func(c *Context) {
+		id := strconv.Itoa(int(c.Header().(IHeader).MsgID()))
+		inFlight := _messagesInFlight.WithLabelValues(id)
+		inFlight.Inc()
+		start := time.Now()
+
+		defer func() {
+			inFlight.Dec()
+			_messagesTotal.WithLabelValues(id).Inc()
+			_messageDuration.WithLabelValues(id).Observe(time.Since(start).Seconds())
+			_requestSize.WithLabelValues(id).Observe(float64(len(c.data)))
+			_responseSize.WithLabelValues(id).Observe(float64(c.size))
+		}()
+		c.Next()
+	}
+}
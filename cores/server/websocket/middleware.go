@@ -38,6 +38,15 @@ func PanicHandler(lgr *logger.Logger) core {
 	}
 }
 
+// RequestIDHandler 每条消息的请求id 消息头中没有对应字段
+// 握手请求携带合法的X-Request-ID时为<id>-<序号> 否则生成
+func RequestIDHandler() core {
+	return func(c *Context) {
+		c.ctx = gCtx.NewRequestIDContext(c.ctx, c.conn.nextRequestID())
+		c.Next()
+	}
+}
+
 func TracingHandler(tracer opentracing.Tracer) core {
 	return func(c *Context) {
 		buffer := bytes.NewBuffer(c.Header().(IHeader).Trace())
@@ -76,6 +85,7 @@ func LogHandler(lgr *logger.Logger, requestEnable bool) core {
 		defer func() {
 			logData := map[string]interface{}{
 				"Peer":      td,
+				"RequestID": gCtx.RequestID(c.ctx),
 				"MessageID": c.Header().(IHeader).MsgID(),
 				"Cost":      fmt.Sprintf("%dms", time.Now().UnixMilli()-start),
 			}
@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	gCtx "github.com/curry-mz/sagittarius-golang/context"
 	"github.com/curry-mz/sagittarius-golang/cores/logger"
 
 	"github.com/gorilla/websocket"
@@ -21,12 +23,23 @@ type Conn struct {
 	cancel     func()
 	remoteAddr string
 	lgr        *logger.Logger
+	// 握手请求的X-Request-ID 不合法时为空
+	requestID string
+	seq       uint64
 }
 
 func (c *Conn) Close() error {
 	return c.c.Close()
 }
 
+// nextRequestID 握手请求携带合法的X-Request-ID时为<id>-<序号> 便于上游关联 否则每条消息生成
+func (c *Conn) nextRequestID() string {
+	if c == nil || c.requestID == "" {
+		return gCtx.NewRequestID()
+	}
+	return gCtx.ChildRequestID(c.requestID, atomic.AddUint64(&c.seq, 1))
+}
+
 func (c *Conn) write() {
 	for {
 		select {
@@ -130,13 +143,25 @@ func (s *Engine) Start(ctx context.Context) error {
 		if !websocket.IsWebSocketUpgrade(r) {
 			return
 		}
-		c, err := s.mux.upGrader.Upgrade(w, r, nil)
+		// 接受握手请求的X-Request-ID 并在响应header回传
+		var respHeader http.Header
+		rid := gCtx.GetRequestIDHttpHeader(r.Header)
+		if gCtx.ValidRequestID(rid) {
+			respHeader = http.Header{}
+			gCtx.SetRequestIDHttpHeader(respHeader, rid)
+		} else {
+			rid = ""
+		}
+		c, err := s.mux.upGrader.Upgrade(w, r, respHeader)
 		if err != nil {
 			return
 		}
 
 		cCtx := context.WithValue(ctx, "upgrade", time.Now().Format("2006-01-02 15:04:05.000"))
 		cCtx = context.WithValue(cCtx, "remote", c.RemoteAddr().String())
+		if rid != "" {
+			cCtx = gCtx.NewRequestIDContext(cCtx, rid)
+		}
 
 		nCtx, fn := context.WithCancel(cCtx)
 		cn := Conn{
@@ -147,6 +172,7 @@ func (s *Engine) Start(ctx context.Context) error {
 			server:     s,
 			remoteAddr: c.RemoteAddr().String(),
 			lgr:        s.lgr,
+			requestID:  rid,
 		}
 		go func(c *Conn) {
 			s.trackConn(c, true)
@@ -0,0 +1,24 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrHandlerTimeout = errors.New("websocket handler timeout")
+
+// Timeout 为Ctx()设置deadline 到期后Context.Write丢弃消息并返回ErrHandlerTimeout
+func Timeout(d time.Duration) core {
+	return func(c *Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.ctx, d)
+		defer cancel()
+		c.ctx = ctx
+		c.Next()
+	}
+}
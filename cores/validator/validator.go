@@ -0,0 +1,126 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	gErrors "github.com/curry-mz/sagittarius-golang/cores/errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+)
+
+const (
+	// InvalidArgument 参数校验失败错误码
+	InvalidArgument = 400
+)
+
+type IValidator interface {
+	Validate(v interface{}) error
+}
+
+var (
+	_validator IValidator = newDefaultValidator()
+)
+
+// SetValidator 替换默认校验器
+func SetValidator(v IValidator) {
+	if v != nil {
+		_validator = v
+	}
+}
+
+// Validate 按validate tag校验结构体 失败时返回携带字段详情的*errors.Error
+func Validate(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	return _validator.Validate(v)
+}
+
+type defaultValidator struct {
+	validate *validator.Validate
+}
+
+func newDefaultValidator() *defaultValidator {
+	v := validator.New()
+	// 字段名使用json tag 与请求参数保持一致
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return &defaultValidator{validate: v}
+}
+
+func (d *defaultValidator) Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	// 只校验结构体
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	err := d.validate.Struct(rv.Interface())
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return err
+	}
+	details := make([]*gErrors.FieldViolation, 0, len(ves))
+	for _, fe := range ves {
+		details = append(details, &gErrors.FieldViolation{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: message(fe),
+		})
+	}
+	return gErrors.New(InvalidArgument, "invalid argument").WithDetails(details...)
+}
+
+// fieldPath 去掉根结构体名 例如User.address.city => address.city
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if idx := strings.Index(ns, "."); idx >= 0 {
+		return ns[idx+1:]
+	}
+	return ns
+}
+
+func message(fe validator.FieldError) string {
+	field := fieldPath(fe)
+	var unit string
+	switch fe.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		unit = " length"
+	}
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min", "gte":
+		return fmt.Sprintf("%s%s must be at least %s", field, unit, fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("%s%s must be at most %s", field, unit, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s%s must be greater than %s", field, unit, fe.Param())
+	case "lt":
+		return fmt.Sprintf("%s%s must be less than %s", field, unit, fe.Param())
+	case "len":
+		return fmt.Sprintf("%s%s must be %s", field, unit, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, fe.Param())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email", field)
+	default:
+		return fmt.Sprintf("%s failed on the '%s' rule", field, fe.Tag())
+	}
+}
@@ -59,11 +59,11 @@ func (b *Builder) ProducerMessage(ctx context.Context, topic string, key []byte,
 		if ok {
 			m.SetUberMeta(fmt.Sprintf("%s.%s.%s", td.Namespace, td.Product, td.ServiceName))
 		}
-		err := b.tracer.Inject(span.Context(), opentracing.TextMap, m)
-		if err != nil {
-			// 注入失败则直接返回消息
-			return &pm
+		if rid, ok := gCtx.FromRequestIDContext(ctx); ok {
+			m.SetRequestID(rid)
 		}
+		// 注入失败时仍传递服务信息和请求id
+		_ = b.tracer.Inject(span.Context(), opentracing.TextMap, m)
 		// 将注入信息写入header进行传递
 		pm.msg.Headers = append(pm.msg.Headers, m.Data...)
 	}
@@ -104,11 +104,18 @@ func (b *Builder) ConsumerMessage(ctx context.Context, message *sarama.ConsumerM
 				ServiceName: strings.Join(ss[2:], "."),
 			})
 		}
+		// 请求id 上游未传递时生成
+		rid := m.GetRequestID()
+		if !gCtx.ValidRequestID(rid) {
+			rid = gCtx.NewRequestID()
+		}
+		ctx = gCtx.NewRequestIDContext(ctx, rid)
 	} else {
 		opts = []opentracing.StartSpanOption{
 			ext.SpanKindConsumer,
 			opentracing.Tag{Key: string(ext.Component), Value: "kafka"},
 		}
+		ctx = gCtx.NewRequestIDContext(ctx, gCtx.NewRequestID())
 	}
 	span := b.tracer.StartSpan(message.Topic, opts...)
 	c := opentracing.ContextWithSpan(ctx, span)
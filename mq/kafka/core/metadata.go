@@ -6,6 +6,7 @@ import (
 
 const (
 	_uberCtxServiceKey = "_uber_ctx_service_key"
+	_requestIDKey      = "x-request-id"
 )
 
 type TextMapMeta struct {
@@ -28,6 +29,22 @@ func (tm *TextMapMeta) GetUberMeta() string {
 	return ""
 }
 
+func (tm *TextMapMeta) SetRequestID(id string) {
+	tm.Data = append(tm.Data, sarama.RecordHeader{
+		Key:   []byte(_requestIDKey),
+		Value: []byte(id),
+	})
+}
+
+func (tm *TextMapMeta) GetRequestID() string {
+	for _, h := range tm.Data {
+		if string(h.Key) == _requestIDKey {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
 func (tm *TextMapMeta) Set(key, val string) {
 	tm.Data = append(tm.Data, sarama.RecordHeader{
 		Key:   []byte(key),
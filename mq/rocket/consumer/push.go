@@ -83,6 +83,12 @@ func (p *PushConsumer) RegisterHandler(topic string, f OnMessage) error {
 	}
 	fn := func(ctx context.Context, msgs ...*primitive.MessageExt) (consumer.ConsumeResult, error) {
 		for _, msg := range msgs {
+			// 请求id 上游未传递时生成
+			rid := metadata.NewMetaMapWithData(msg.GetProperties()).GetRequestID()
+			if !gCtx.ValidRequestID(rid) {
+				rid = gCtx.NewRequestID()
+			}
+			ctx = gCtx.NewRequestIDContext(ctx, rid)
 			if p.tracer != nil {
 				var opts []opentracing.StartSpanOption
 				// 从header中获取spanContext,如果没有，则这里新建一个
@@ -112,7 +118,7 @@ func (p *PushConsumer) RegisterHandler(topic string, f OnMessage) error {
 					}
 				}
 				span := p.tracer.StartSpan(msg.Topic, opts...)
-				ctx = opentracing.ContextWithSpan(context.TODO(), span)
+				ctx = opentracing.ContextWithSpan(ctx, span)
 				defer span.Finish()
 			}
 		}
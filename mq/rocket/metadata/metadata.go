@@ -2,6 +2,7 @@ package metadata
 
 const (
 	_uberCtxServiceKey = "_uber_ctx_service_key"
+	_requestIDKey      = "x-request-id"
 )
 
 type MetaMap struct {
@@ -28,6 +29,14 @@ func (mm *MetaMap) GetUberMeta() string {
 	return mm.data[_uberCtxServiceKey]
 }
 
+func (mm *MetaMap) SetRequestID(id string) {
+	mm.data[_requestIDKey] = id
+}
+
+func (mm *MetaMap) GetRequestID() string {
+	return mm.data[_requestIDKey]
+}
+
 func (mm *MetaMap) Set(key, val string) {
 	mm.data[key] = val
 }
@@ -86,6 +86,14 @@ func (p *Producer) SyncSend(ctx context.Context, topic string,
 	if o.tags != "" {
 		msg = msg.WithTag(o.tags)
 	}
+	// 请求id
+	if rid, ok := gCtx.FromRequestIDContext(ctx); ok {
+		m := metadata.NewMetaMap()
+		m.SetRequestID(rid)
+		for k, v := range m.Data() {
+			msg.WithProperty(k, v)
+		}
+	}
 	// 链路追踪
 	if p.tracer != nil {
 		// 从context中获取spanContext,如果上层没有开启追踪，则这里新建一个
@@ -110,8 +118,10 @@ func (p *Producer) SyncSend(ctx context.Context, topic string,
 			// 注入失败则直接发送消息
 			return p.cli.SendSync(ctx, msg)
 		}
-		// 将注入信息写入header进行传递
-		msg.WithProperties(m.Data())
+		// 将注入信息写入header进行传递 不覆盖tag/keys等已有属性
+		for k, v := range m.Data() {
+			msg.WithProperty(k, v)
+		}
 	}
 	// 发送消息
 	return p.cli.SendSync(ctx, msg)